@@ -0,0 +1,202 @@
+// Package cache manages local copies of circle csv files downloaded from remote sources.
+//
+// Each source URL has its own entry directory under the cache directory,
+// which contains the downloaded csv, its books file and the metadata about when and from where it was fetched.
+// Circle images are kept in the content-addressed ImageStore in the cache directory.
+package cache
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/mpppk/tbf/fsutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	csvFileName       = "circles.csv"
+	booksFileName     = "circles.books.json"
+	fetchMetaFileName = "fetch.json"
+)
+
+// DefaultDir returns the default cache directory.
+// It is $XDG_CACHE_HOME/tbf, or ~/.cache/tbf if XDG_CACHE_HOME is not set.
+func DefaultDir() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "tbf"), nil
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find home directory")
+	}
+	return filepath.Join(home, ".cache", "tbf"), nil
+}
+
+// Cache is a directory which holds cached circle csv files.
+type Cache struct {
+	Dir string
+}
+
+// New returns a Cache on dir. The directory is created lazily when an entry is created.
+func New(dir string) *Cache {
+	return &Cache{Dir: dir}
+}
+
+var invalidKeyChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Key returns the cache key of sourceURL.
+// The key is a file name safe representation of the host and the path of the URL like
+// "raw.githubusercontent.com_mpppk_tbf_master_data_tbf5_circles.csv".
+func Key(sourceURL string) string {
+	key := sourceURL
+	if u, err := url.Parse(sourceURL); err == nil && u.Host != "" {
+		key = u.Host + u.Path
+		if u.RawQuery != "" {
+			key += "?" + u.RawQuery
+		}
+	}
+	// leading dots are trimmed so that the key never points to outside of the cache directory
+	return strings.TrimRight(strings.TrimLeft(invalidKeyChars.ReplaceAllString(key, "_"), "._"), "_")
+}
+
+// Entry returns the cache entry of sourceURL. The entry may not exist yet.
+func (c *Cache) Entry(sourceURL string) *Entry {
+	return c.entry(Key(sourceURL))
+}
+
+func (c *Cache) entry(key string) *Entry {
+	return &Entry{
+		Key: key,
+		Dir: filepath.Join(c.Dir, key),
+	}
+}
+
+// List returns the existing cache entries sorted by key.
+func (c *Cache) List() ([]*Entry, error) {
+	fileInfos, err := ioutil.ReadDir(c.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read cache directory: "+c.Dir)
+	}
+
+	var entries []*Entry
+	for _, fileInfo := range fileInfos {
+		// the image store is not a cache entry of csv
+		if !fileInfo.IsDir() || fileInfo.Name() == imagesDirName {
+			continue
+		}
+		entries = append(entries, c.entry(fileInfo.Name()))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+// Remove removes the cache entry of key.
+func (c *Cache) Remove(key string) error {
+	entry := c.entry(key)
+	if key == "" || filepath.Dir(entry.Dir) != filepath.Clean(c.Dir) {
+		return errors.New("invalid cache key: " + key)
+	}
+	if _, err := os.Stat(entry.Dir); err != nil {
+		return errors.Wrap(err, "cache entry not found: "+key)
+	}
+	return errors.Wrap(os.RemoveAll(entry.Dir), "failed to remove cache entry: "+key)
+}
+
+// Clear removes all cache entries.
+func (c *Cache) Clear() error {
+	entries, err := c.List()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := c.Remove(entry.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FetchMeta records when and from where the cached csv was fetched.
+type FetchMeta struct {
+	// URL is the URL which the csv was downloaded from.
+	URL string `json:"url"`
+	// Source is the source name given by the user like "tbf5" or the URL itself.
+	Source string `json:"source"`
+	// FetchedAt is the time when the csv was downloaded.
+	FetchedAt time.Time `json:"fetched_at"`
+	// CheckedAt is the time when the csv was last confirmed to be up to date.
+	CheckedAt time.Time `json:"checked_at"`
+	// ETag and LastModified are the HTTP validators of the cached csv which are used for conditional requests.
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// Entry is a cached csv of a source.
+type Entry struct {
+	Key string
+	Dir string
+}
+
+// CSVFilePath returns the path of the cached csv.
+func (e *Entry) CSVFilePath() string {
+	return filepath.Join(e.Dir, csvFileName)
+}
+
+// BooksFilePath returns the path of the cached books file.
+func (e *Entry) BooksFilePath() string {
+	return filepath.Join(e.Dir, booksFileName)
+}
+
+// FetchMetaFilePath returns the path of the fetch metadata.
+func (e *Entry) FetchMetaFilePath() string {
+	return filepath.Join(e.Dir, fetchMetaFileName)
+}
+
+// Create creates the entry directory if it does not exist.
+func (e *Entry) Create() error {
+	return errors.Wrap(os.MkdirAll(e.Dir, 0755), "failed to create cache directory: "+e.Dir)
+}
+
+// HasCSV returns true if the csv of the entry is cached.
+func (e *Entry) HasCSV() bool {
+	_, err := os.Stat(e.CSVFilePath())
+	return err == nil
+}
+
+// ReadFetchMeta reads the fetch metadata of the entry.
+func (e *Entry) ReadFetchMeta() (*FetchMeta, error) {
+	contents, err := ioutil.ReadFile(e.FetchMetaFilePath())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read fetch metadata of "+e.Key)
+	}
+	meta := &FetchMeta{}
+	if err := json.Unmarshal(contents, meta); err != nil {
+		return nil, errors.Wrap(err, "failed to parse fetch metadata of "+e.Key)
+	}
+	return meta, nil
+}
+
+// WriteFetchMeta writes the fetch metadata of the entry.
+func (e *Entry) WriteFetchMeta(meta *FetchMeta) error {
+	contents, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal fetch metadata")
+	}
+	if err := e.Create(); err != nil {
+		return err
+	}
+	return errors.Wrap(
+		fsutil.WriteFileAtomically(e.FetchMetaFilePath(), contents),
+		"failed to write fetch metadata of "+e.Key)
+}
@@ -0,0 +1,109 @@
+package cache_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mpppk/tbf/cache"
+)
+
+func newTempCache(t *testing.T) (*cache.Cache, func()) {
+	dir, err := ioutil.TempDir("", "tbf-cache-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	return cache.New(dir), func() { os.RemoveAll(dir) }
+}
+
+func TestKey(t *testing.T) {
+	cases := []struct {
+		url      string
+		expected string
+	}{
+		{
+			url:      "https://raw.githubusercontent.com/mpppk/tbf/master/data/tbf5_circles.csv",
+			expected: "raw.githubusercontent.com_mpppk_tbf_master_data_tbf5_circles.csv",
+		},
+		{
+			url:      "http://example.com/circles.csv?event=tbf05",
+			expected: "example.com_circles.csv_event_tbf05",
+		},
+		{
+			url:      "../../etc/passwd",
+			expected: "etc_passwd",
+		},
+	}
+
+	for _, c := range cases {
+		if actual := cache.Key(c.url); actual != c.expected {
+			t.Errorf("Key is expected to return %q when %q is given, but actually return %q", c.expected, c.url, actual)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	c, cleanup := newTempCache(t)
+	defer cleanup()
+
+	entries, err := c.List()
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("empty cache is expected to have no entries, but actually has %d", len(entries))
+	}
+
+	urls := []string{"https://example.com/b.csv", "https://example.com/a.csv"}
+	fetchedAt := time.Date(2018, 10, 8, 11, 0, 0, 0, time.UTC)
+	for _, u := range urls {
+		entry := c.Entry(u)
+		if entry.HasCSV() {
+			t.Errorf("entry of %s is not expected to have csv before it is written", u)
+		}
+		if err := entry.WriteFetchMeta(&cache.FetchMeta{URL: u, Source: u, FetchedAt: fetchedAt}); err != nil {
+			t.Fatalf("Unexpected error occurred: %s", err)
+		}
+		if err := ioutil.WriteFile(entry.CSVFilePath(), []byte("DetailURL\n"), 0644); err != nil {
+			t.Fatalf("Unexpected error occurred: %s", err)
+		}
+	}
+
+	entries, err = c.List()
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if len(entries) != 2 || entries[0].Key != "example.com_a.csv" || entries[1].Key != "example.com_b.csv" {
+		t.Fatalf("List is expected to return entries sorted by key, but actually return %v", entries)
+	}
+
+	meta, err := entries[0].ReadFetchMeta()
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if meta.URL != "https://example.com/a.csv" || !meta.FetchedAt.Equal(fetchedAt) {
+		t.Errorf("unexpected fetch metadata: %#v", meta)
+	}
+
+	if err := c.Remove(".."); err == nil {
+		t.Errorf("Remove is expected to be error if key points outside of cache directory")
+	}
+	if err := c.Remove(entries[0].Key); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if entries[0].HasCSV() {
+		t.Errorf("removed entry is not expected to have csv")
+	}
+
+	if err := c.Clear(); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	entries, err = c.List()
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("cleared cache is expected to have no entries, but actually has %d", len(entries))
+	}
+}
@@ -0,0 +1,233 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/mpppk/tbf/csv"
+	"github.com/mpppk/tbf/fsutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	imagesDirName      = "images"
+	imageIndexFileName = "index.json"
+)
+
+// imageExts are the file extensions of the image types which the circle images are known to have.
+var imageExts = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+var imageHashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// StoredImage is an image in ImageStore.
+type StoredImage struct {
+	// Hash is the hex encoded SHA-256 of the image.
+	Hash string `json:"hash"`
+	// Path is the slash separated path of the image file relative to the store directory.
+	Path        string    `json:"path"`
+	ContentType string    `json:"content_type,omitempty"`
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
+// ImageStore is a content-addressed store of circle images.
+// Each image is saved as <first 2 characters of hash>/<hash><extension> under Dir, where hash is the hex encoded SHA-256
+// of the image, so the same image is saved only once even if it is downloaded from several URLs.
+// The index file of the store maps the URLs which the images were downloaded from to the images.
+// It is safe for concurrent use.
+type ImageStore struct {
+	Dir string
+	// Client is used to download images. The HTTP client of the csv package is used if it is nil.
+	Client *csv.HTTPClient
+
+	m sync.Mutex
+}
+
+// NewImageStore returns an ImageStore on dir. The directory is created lazily when an image is saved.
+func NewImageStore(dir string) *ImageStore {
+	return &ImageStore{Dir: dir}
+}
+
+// Images returns the image store in the cache directory.
+func (c *Cache) Images() *ImageStore {
+	return NewImageStore(filepath.Join(c.Dir, imagesDirName))
+}
+
+// FilePath returns the path of the file of image.
+func (s *ImageStore) FilePath(image *StoredImage) string {
+	return filepath.Join(s.Dir, filepath.FromSlash(image.Path))
+}
+
+// Get returns the image whose hash is hash. It returns false if the image is not stored.
+func (s *ImageStore) Get(hash string) (*StoredImage, bool) {
+	if !imageHashPattern.MatchString(hash) {
+		return nil, false
+	}
+	filePaths, err := filepath.Glob(filepath.Join(s.Dir, hash[:2], hash+"*"))
+	if err != nil || len(filePaths) == 0 {
+		return nil, false
+	}
+	return &StoredImage{
+		Hash:        hash,
+		Path:        path.Join(hash[:2], filepath.Base(filePaths[0])),
+		ContentType: mime.TypeByExtension(filepath.Ext(filePaths[0])),
+	}, true
+}
+
+// Lookup returns the image which was downloaded from imageURL. It returns false if the image is not stored.
+func (s *ImageStore) Lookup(imageURL string) (*StoredImage, bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	index, err := s.readIndex()
+	if err != nil {
+		return nil, false
+	}
+	image, ok := index[imageURL]
+	if !ok {
+		return nil, false
+	}
+	if _, err := os.Stat(s.FilePath(image)); err != nil {
+		return nil, false
+	}
+	return image, true
+}
+
+// Put saves contents as an image and returns it.
+// The extension of the file is decided by contentType, or by the contents if contentType is not an image type.
+func (s *ImageStore) Put(contents []byte, contentType string) (*StoredImage, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.put(contents, contentType)
+}
+
+func (s *ImageStore) put(contents []byte, contentType string) (*StoredImage, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if _, ok := imageExts[mediaType]; err != nil || !ok {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(contents))
+	}
+
+	digest := sha256.Sum256(contents)
+	hash := hex.EncodeToString(digest[:])
+	image := &StoredImage{
+		Hash:        hash,
+		Path:        path.Join(hash[:2], hash+imageExts[mediaType]),
+		ContentType: mediaType,
+		FetchedAt:   time.Now(),
+	}
+
+	filePath := s.FilePath(image)
+	if _, err := os.Stat(filePath); err == nil {
+		return image, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return nil, errors.Wrap(err, "failed to create image directory: "+filepath.Dir(filePath))
+	}
+	if err := fsutil.WriteFileAtomically(filePath, contents); err != nil {
+		return nil, errors.Wrap(err, "failed to save image to "+filePath)
+	}
+	return image, nil
+}
+
+// Fetch downloads the image on imageURL to the store and returns it.
+// The image is not downloaded again if it has already been downloaded from imageURL.
+func (s *ImageStore) Fetch(ctx context.Context, imageURL string) (*StoredImage, error) {
+	if image, ok := s.Lookup(imageURL); ok {
+		return image, nil
+	}
+
+	contents, contentType, err := s.download(ctx, imageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	image, err := s.put(contents, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := s.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	index[imageURL] = image
+	if err := s.writeIndex(index); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+func (s *ImageStore) download(ctx context.Context, imageURL string) ([]byte, string, error) {
+	req, err := http.NewRequest(http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to create request to "+imageURL)
+	}
+	req = req.WithContext(ctx)
+
+	client := s.Client
+	if client == nil {
+		client = csv.GetHTTPClient()
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to download image from "+imageURL)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to download image from %s: %s", imageURL, res.Status)
+	}
+	contents, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to read image from "+imageURL)
+	}
+	return contents, res.Header.Get("Content-Type"), nil
+}
+
+func (s *ImageStore) indexFilePath() string {
+	return filepath.Join(s.Dir, imageIndexFileName)
+}
+
+// readIndex reads the index file of the store. Empty index is returned if the file does not exist.
+func (s *ImageStore) readIndex() (map[string]*StoredImage, error) {
+	contents, err := ioutil.ReadFile(s.indexFilePath())
+	if os.IsNotExist(err) {
+		return map[string]*StoredImage{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read image index: "+s.indexFilePath())
+	}
+
+	index := map[string]*StoredImage{}
+	if err := json.Unmarshal(contents, &index); err != nil {
+		return nil, errors.Wrap(err, "failed to parse image index: "+s.indexFilePath())
+	}
+	return index, nil
+}
+
+func (s *ImageStore) writeIndex(index map[string]*StoredImage) error {
+	contents, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal image index")
+	}
+	return errors.Wrap(fsutil.WriteFileAtomically(s.indexFilePath(), contents), "failed to write image index: "+s.indexFilePath())
+}
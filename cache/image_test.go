@@ -0,0 +1,142 @@
+package cache_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mpppk/tbf/csv"
+)
+
+// pngHeader is the signature of PNG, which is enough for content type detection.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+func TestImageStore_Put(t *testing.T) {
+	c, cleanup := newTempCache(t)
+	defer cleanup()
+	store := c.Images()
+
+	digest := sha256.Sum256(pngHeader)
+	hash := hex.EncodeToString(digest[:])
+
+	cases := []struct {
+		contentType  string
+		expectedPath string
+	}{
+		{contentType: "image/png", expectedPath: hash[:2] + "/" + hash + ".png"},
+		{contentType: "application/octet-stream", expectedPath: hash[:2] + "/" + hash + ".png"},
+		{contentType: "", expectedPath: hash[:2] + "/" + hash + ".png"},
+	}
+
+	for _, c := range cases {
+		image, err := store.Put(pngHeader, c.contentType)
+		if err != nil {
+			t.Errorf("Unexpected error occurred when %q is given: %s", c.contentType, err)
+			continue
+		}
+		if image.Hash != hash || image.Path != c.expectedPath {
+			t.Errorf("image is expected to be saved to %s when %q is given, but actually %#v", c.expectedPath, c.contentType, image)
+		}
+	}
+
+	image, ok := store.Get(hash)
+	if !ok {
+		t.Fatalf("image %s is expected to be stored", hash)
+	}
+	contents, err := ioutil.ReadFile(store.FilePath(image))
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if string(contents) != string(pngHeader) {
+		t.Errorf("stored image is expected to be %q, but actually %q", pngHeader, contents)
+	}
+
+	for _, invalidHash := range []string{"", "abc", "../" + hash[3:]} {
+		if _, ok := store.Get(invalidHash); ok {
+			t.Errorf("Get is expected to return false if %q is given", invalidHash)
+		}
+	}
+}
+
+func TestImageStore_Fetch(t *testing.T) {
+	c, cleanup := newTempCache(t)
+	defer cleanup()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path == "/expired" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngHeader)
+	}))
+	defer server.Close()
+
+	store := c.Images()
+	client, err := csv.NewHTTPClient(&csv.HTTPConfig{Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	store.Client = client
+
+	image1, err := store.Fetch(context.Background(), server.URL+"/1")
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	image2, err := store.Fetch(context.Background(), server.URL+"/2")
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if image1.Path != image2.Path {
+		t.Errorf("same images are expected to be saved to the same file, but actually %s and %s", image1.Path, image2.Path)
+	}
+
+	if _, err := store.Fetch(context.Background(), server.URL+"/1"); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if requests := atomic.LoadInt32(&requests); requests != 2 {
+		t.Errorf("image which has already been fetched is expected not to be downloaded again, but %d requests are sent", requests)
+	}
+	if image, ok := store.Lookup(server.URL + "/2"); !ok || image.Hash != image2.Hash {
+		t.Errorf("fetched image is expected to be looked up by its URL, but actually %#v", image)
+	}
+
+	if _, err := store.Fetch(context.Background(), server.URL+"/expired"); err == nil {
+		t.Errorf("Fetch is expected to be error if the image is not found")
+	}
+	if _, ok := store.Lookup(server.URL + "/expired"); ok {
+		t.Errorf("image which failed to be fetched is expected not to be stored")
+	}
+
+	if err := os.Remove(store.FilePath(image1)); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if _, ok := store.Lookup(server.URL + "/1"); ok {
+		t.Errorf("image whose file is removed is expected not to be looked up")
+	}
+}
+
+func TestCache_List_images(t *testing.T) {
+	c, cleanup := newTempCache(t)
+	defer cleanup()
+
+	if _, err := c.Images().Put(pngHeader, "image/png"); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	entries, err := c.List()
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("image store is expected not to be listed as cache entry, but actually %#v", entries[0])
+	}
+}
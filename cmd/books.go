@@ -0,0 +1,176 @@
+// Copyright © 2018 mpppk <niboshiporipori@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mpppk/tbf/format"
+	"github.com/mpppk/tbf/tbf"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var newOnlyKey = "new"
+
+// booksCmd represents the books command
+var booksCmd = &cobra.Command{
+	Use:   "books",
+	Short: "頒布物の情報を操作します",
+	Long: `頒布物の情報は、tbf crawlがサークル詳細ページから取得し、csvと同じディレクトリの[csvファイル名].books.jsonに保存します。
+頒布物はサークル詳細ページのURL(CircleDetailURL)とスペースでサークルと紐付けられます。
+ソースがURLやイベント名の場合は、csvと同じ場所の.books.jsonをダウンロードして使います。`,
+}
+
+// booksListCmd represents the books list command
+var booksListCmd = &cobra.Command{
+	Use:   "list [space...]",
+	Short: "頒布物の一覧を表示します",
+	Long: `--sourceで指定したサークル情報の頒布物をスペース順に表示します
+引数としてスペース名を与えると、そのサークルの頒布物だけを表示します
+ex)
+$ tbf books list
+$ tbf books list あ01 あ02 --output table
+$ tbf books list --new --output 'template={{.Space}} {{.Title}} {{.Price}}'`,
+	Run: func(cmd *cobra.Command, args []string) {
+		formatter, err := newBookFormatter(cmd)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		books, err := loadBooks(cmd)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		newOnly, err := cmd.Flags().GetBool(newOnlyKey)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		books, err = filterBooks(books, args, newOnly)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		tbf.SortBooks(books)
+
+		if err := formatter.FormatBooks(os.Stdout, books); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+// filterBooks returns books of the circles on spaces. All books are returned if spaces are empty.
+// Only new books are returned if newOnly is true.
+func filterBooks(books []*tbf.Book, spaces []string, newOnly bool) ([]*tbf.Book, error) {
+	spaceSet := map[string]bool{}
+	for _, space := range spaces {
+		if _, err := tbf.ParseSpace(space); err != nil {
+			return nil, err
+		}
+		spaceSet[tbf.NormalizeSpace(space)] = true
+	}
+
+	var filtered []*tbf.Book
+	for _, book := range books {
+		if len(spaceSet) > 0 && !spaceSet[tbf.NormalizeSpace(book.Space)] {
+			continue
+		}
+		if newOnly && !book.IsNew {
+			continue
+		}
+		filtered = append(filtered, book)
+	}
+	return filtered, nil
+}
+
+// circleDetailWithBooks is a circle detail with its books, which is written by `tbf describe --books` in json.
+type circleDetailWithBooks struct {
+	*tbf.CircleDetail
+	Books []*tbf.Book
+}
+
+// validateOutputWithBooks returns error if output can not be used with the books of circles.
+func validateOutputWithBooks(output string) error {
+	if output == "text" || output == "json" || output == "ndjson" || strings.HasPrefix(output, format.TemplatePrefix) {
+		return nil
+	}
+	return fmt.Errorf("output %s can not be used with --%s (available: text, json, ndjson, %s...)", output, booksKey, format.TemplatePrefix)
+}
+
+// writeCircleDetailsWithBooks writes circle details with their books in booksMap, which is keyed by CircleDetailURL.
+// Books are written in Books field of each circle detail in json outputs,
+// and in indented lines after each circle detail in other outputs.
+func writeCircleDetailsWithBooks(w io.Writer, formatter format.Formatter, output string, circleDetails []*tbf.CircleDetail,
+	booksMap map[string][]*tbf.Book) error {
+	if output == "json" || output == "ndjson" {
+		items := []*circleDetailWithBooks{}
+		for _, circleDetail := range circleDetails {
+			books := booksMap[circleDetail.DetailURL]
+			if books == nil {
+				books = []*tbf.Book{}
+			}
+			items = append(items, &circleDetailWithBooks{CircleDetail: circleDetail, Books: books})
+		}
+
+		encoder := json.NewEncoder(w)
+		if output == "json" {
+			encoder.SetIndent("", "  ")
+			return errors.Wrap(encoder.Encode(items), "failed to write circle details as json")
+		}
+		for _, item := range items {
+			if err := encoder.Encode(item); err != nil {
+				return errors.Wrap(err, "failed to write circle detail as json: "+item.Space)
+			}
+		}
+		return nil
+	}
+
+	for _, circleDetail := range circleDetails {
+		if err := formatter.Format(w, []*tbf.CircleDetail{circleDetail}); err != nil {
+			return err
+		}
+		for _, book := range booksMap[circleDetail.DetailURL] {
+			if _, err := fmt.Fprintf(w, "  %s\n", format.BookSummary(book)); err != nil {
+				return errors.Wrap(err, "failed to write book")
+			}
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(booksCmd)
+	booksCmd.AddCommand(booksListCmd)
+
+	addSourceFlag(booksListCmd)
+	addBookOutputFlag(booksListCmd, "text")
+	booksListCmd.Flags().Bool(newOnlyKey, false, "新刊だけを表示する")
+}
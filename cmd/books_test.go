@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mpppk/tbf/format"
+	"github.com/mpppk/tbf/tbf"
+)
+
+func TestFilterBooks(t *testing.T) {
+	books := []*tbf.Book{
+		{Space: "あ01", Title: "book1", IsNew: true},
+		{Space: "あ02", Title: "book2"},
+		{Space: "あ02", Title: "book3", IsNew: true},
+	}
+
+	cases := []struct {
+		spaces      []string
+		newOnly     bool
+		expected    []string
+		willBeError bool
+	}{
+		{expected: []string{"book1", "book2", "book3"}},
+		{spaces: []string{"あ2"}, expected: []string{"book2", "book3"}},
+		{spaces: []string{"あ02"}, newOnly: true, expected: []string{"book3"}},
+		{spaces: []string{"invalid"}, willBeError: true},
+	}
+
+	for _, c := range cases {
+		filtered, err := filterBooks(books, c.spaces, c.newOnly)
+		if c.willBeError {
+			if err == nil {
+				t.Errorf("filterBooks is expected to be error if %v is given", c.spaces)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error occurred when %v is given: %s", c.spaces, err)
+			continue
+		}
+		var titles []string
+		for _, book := range filtered {
+			titles = append(titles, book.Title)
+		}
+		if len(titles) != len(c.expected) {
+			t.Errorf("books %v are expected when %v (new only: %t) is given, but actually %v", c.expected, c.spaces, c.newOnly, titles)
+			continue
+		}
+		for i := range titles {
+			if titles[i] != c.expected[i] {
+				t.Errorf("books %v are expected when %v (new only: %t) is given, but actually %v", c.expected, c.spaces, c.newOnly, titles)
+				break
+			}
+		}
+	}
+}
+
+func TestWriteCircleDetailsWithBooks(t *testing.T) {
+	const detailURL = "https://techbookfest.org/event/tbf05/circle/1"
+	circleDetails := []*tbf.CircleDetail{
+		{Circle: tbf.Circle{DetailURL: detailURL, Space: "あ01", Name: "name1", Penname: "penname1", Genre: "科学技術"}},
+	}
+	booksMap := map[string][]*tbf.Book{
+		detailURL: {{CircleDetailURL: detailURL, Space: "あ01", Title: "book1", Price: 500, IsNew: true}},
+	}
+
+	cases := []struct {
+		output   string
+		expected string
+	}{
+		{
+			output:   "text",
+			expected: "あ01 name1 by penname1 【科学技術】 : \n  [新刊] book1 500円\n",
+		},
+		{
+			output: "ndjson",
+			expected: `{"DetailURL":"https://techbookfest.org/event/tbf05/circle/1","Space":"あ01","Name":"name1",` +
+				`"Penname":"penname1","Genre":"科学技術","ImageURL":"","WebURL":"","GenreFreeFormat":"","ImageHash":"","ImagePath":"",` +
+				`"Books":[{"CircleDetailURL":"https://techbookfest.org/event/tbf05/circle/1","Space":"あ01","Title":"book1",` +
+				`"Price":500,"PriceText":"","Pages":0,"Format":"","IsNew":true,"Description":"","ImageURLs":null}]}` + "\n",
+		},
+	}
+
+	for _, c := range cases {
+		if err := validateOutputWithBooks(c.output); err != nil {
+			t.Fatalf("Unexpected error occurred when %q is given: %s", c.output, err)
+		}
+		formatter, err := format.New(c.output)
+		if err != nil {
+			t.Fatalf("Unexpected error occurred when %q is given: %s", c.output, err)
+		}
+		buf := &bytes.Buffer{}
+		if err := writeCircleDetailsWithBooks(buf, formatter, c.output, circleDetails, booksMap); err != nil {
+			t.Errorf("Unexpected error occurred when %q is given: %s", c.output, err)
+			continue
+		}
+		if actual := buf.String(); actual != c.expected {
+			t.Errorf("output %q is expected to be %q, but actually %q", c.output, c.expected, actual)
+		}
+	}
+
+	if err := validateOutputWithBooks("csv"); err == nil {
+		t.Errorf("validateOutputWithBooks is expected to be error if csv is given")
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright © 2018 mpppk <niboshiporipori@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "ダウンロードしたサークル情報csvのキャッシュを操作します",
+	Long: `tbf listやtbf describeがダウンロードしたcsvはキャッシュディレクトリにソースごとに保存されます。
+キャッシュディレクトリは$XDG_CACHE_HOME/tbf(XDG_CACHE_HOMEが未設定の場合は~/.cache/tbf)で、--cache-dirで変更できます。
+--offlineを指定すると、ネットワークにアクセスせずキャッシュ済みのcsvのみを利用します。`,
+}
+
+// cachePathCmd represents the cache path command
+var cachePathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "キャッシュディレクトリのパスを表示します",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := newCache()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(c.Dir)
+	},
+}
+
+// cacheLsCmd represents the cache ls command
+var cacheLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "キャッシュ済みのcsvの一覧を表示します",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := newCache()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		entries, err := c.List()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tSOURCE\tFETCHED AT\tCHECKED AT\tURL")
+		for _, entry := range entries {
+			meta, err := entry.ReadFetchMeta()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+				fmt.Fprintf(w, "%s\t-\t-\t-\t-\n", entry.Key)
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				entry.Key, meta.Source, formatTime(meta.FetchedAt), formatTime(meta.CheckedAt), meta.URL)
+		}
+		if err := w.Flush(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+// cacheClearCmd represents the cache clear command
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear [keys]",
+	Short: "キャッシュを削除します",
+	Long: `引数として与えられたキー(tbf cache lsで表示されるKEY)のキャッシュを削除します。
+引数を省略した場合は全てのキャッシュを削除します。
+ex)
+$ tbf cache clear
+$ tbf cache clear raw.githubusercontent.com_mpppk_tbf_master_data_tbf5_circles.csv
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := newCache()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		if len(args) == 0 {
+			if err := c.Clear(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "all caches in %s are removed\n", c.Dir)
+			return
+		}
+
+		failed := false
+		for _, key := range args {
+			if err := c.Remove(key); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed = true
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "cache %s is removed\n", key)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cachePathCmd)
+	cacheCmd.AddCommand(cacheLsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+}
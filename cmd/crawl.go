@@ -22,15 +22,20 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
+	"sync"
 
 	"context"
 	"time"
 
 	"os"
+	"path/filepath"
 
+	"github.com/mpppk/tbf/cache"
 	"github.com/mpppk/tbf/crawl"
 	"github.com/mpppk/tbf/csv"
 	"github.com/mpppk/tbf/tbf"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -38,87 +43,565 @@ import (
 var fileKey = "file"
 var urlKey = "url"
 var sleepKey = "sleep"
+var refreshKey = "refresh"
+var maxAgeKey = "max-age"
+var resumeKey = "resume"
+var maxAttemptsKey = "max-attempts"
+var workersKey = "workers"
+var rateKey = "rate"
+var jitterKey = "jitter"
+var strictKey = "strict"
+var backendKey = "backend"
+var recordKey = "record"
+var replayKey = "replay"
+var imagesKey = "images"
 
 var crawlCmd = &cobra.Command{
 	Use:   "crawl",
 	Short: "技術書典のウェブサイトをスクレイピングしてcsvとして保存",
 	Long: `技術書典のウェブサイトをスクレイピングし、サークル情報を--fileで指定した名前のcsvとして書き込みます。
-スクレイピングにはchromeを利用するため、実行する環境にあらかじめインストールしておく必要があります。`,
+サークル一覧のURLは--urlで指定しない場合、--eventで指定したイベント(デフォルトは最新のイベント)のものが使われます。
+スクレイピングにはデフォルトでchromeを利用するため、実行する環境にあらかじめインストールしておく必要があります。
+--backend httpを指定すると、chromeを使わずにHTTPリクエストで取得したHTMLからサークル情報を抽出します。
+この場合JavaScriptは実行されないため、サーバーサイドでレンダリングされたページのみクロールできます。
+
+デフォルトではcsvに存在しないサークルのみを取得して追記します。
+--refreshを指定すると、サークル一覧の情報(スペース, サークル名, ペンネーム)がcsvと異なるサークルと、
+最後に取得してから--max-ageより時間が経ったサークルの詳細を再取得し、csvの行を更新します。
+各サークルの取得日時はcsvと同じディレクトリの[csvファイル名].fetched.jsonに記録されます。
+
+クロールの進捗は[csvファイル名].journal.jsonに記録されます。
+詳細の取得に失敗したサークルは後で--max-attempts回までリトライされ、それでも失敗したサークルがある場合はジャーナルが残ります。
+スペースが不正なサークルなど、再取得しても意味のないサークルはスキップして警告を表示し、リトライしません。
+--resumeを指定すると、中断したクロールや失敗したサークルの取得をジャーナルから再開します。
+
+--workersで指定した数のタブで並行してサークル詳細を取得します。
+すべてのタブからのリクエストは合計で毎秒--rate回以下に制限され、それぞれに最大--jitterのランダムな待ち時間が加わります。
+ex)
+$ tbf crawl --refresh --max-age 24h
+$ tbf crawl --resume
+$ tbf crawl --workers 4 --rate 0.5 --jitter 2s
+
+サークル情報の抽出に使うCSSセレクタは、イベントごとのセレクタプロファイルで定義されています。
+ウェブサイトのデザインが変わった場合は、--selectorsでYAML形式のプロファイルファイルを指定できます。
+プロファイルはtbf selectors checkで保存したHTMLに対して検証できます。
+サークル詳細の表は行のラベル(スペース, ペンネーム, Webサイト, ジャンルなど)で読み取り、プロファイルにないラベルや見つからないラベルは警告を表示します。
+--strictを指定すると、ラベルが見つからない項目があるサークルは取得失敗としてスキップします。
+
+--recordを指定すると、クロール中に訪れたすべてのページのHTMLと抽出結果を指定したディレクトリに保存します。
+--replayを指定すると、保存したページをローカルのサーバーから配信して同じクロールを実行し、抽出結果が保存時と異なるページを報告します。
+リプレイ時はウェブサイトにアクセスしないため、--rateを指定しない限りリクエスト数は制限されず、
+--fileを指定しない限り結果はリプレイするディレクトリのcircles.csvに書き込まれます。
+ex)
+$ tbf crawl --record ./session
+$ tbf crawl --replay ./session --selectors ./my-selectors.yaml
+
+--imagesを指定すると、サークルカット画像をダウンロードして画像の保存先(--image-dir)に保存し、
+画像のハッシュと保存先からの相対パスをcsvのImageHash, ImagePathに記録します。`,
 
 	Run: func(cmd *cobra.Command, args []string) {
-		csvFilePath := viper.GetString(fileKey)
-		circlesURL := viper.GetString(urlKey)
-		sleep := time.Duration(viper.GetInt(sleepKey)) * time.Second
-		crawler, err := crawl.NewTBFCrawler(context.Background(), tbf.BaseURL)
+		opts, err := newCrawlOptions(cmd)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "wait error: %v", err)
+			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 
-		circleCSV, err := csv.NewCircleCSV(csvFilePath)
+		selectors, err := getSelectors(cmd, opts.event)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to prepare csv: %v", err)
+			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 
-		circleDetailMap, err := circleCSV.ToCircleDetailMap()
+		crawler, err := newCrawler(opts, selectors)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to parse csv: %v", err)
+			fmt.Fprintf(os.Stderr, "failed to start crawler: %v\n", err)
 			os.Exit(1)
 		}
+		crawler.SetStrict(viper.GetBool(strictKey))
 
-		// TODO: Add timeout
-		circles, err := crawler.FetchCircles(context.Background(), circlesURL)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to fetch circle information: %v", err)
-			os.Exit(1)
+		rate := getCrawlRate(cmd)
+		if opts.replayDir != "" && !cmd.Flags().Changed(rateKey) {
+			// the local server of the recorded pages does not need to be protected
+			rate = 0
 		}
+		crawler.SetRateLimiter(crawl.NewRateLimiter(rate, 1, viper.GetDuration(jitterKey)))
 
-		filteredCircles := crawl.FilterCircles(circles, circleDetailMap)
-
-		for i, circle := range filteredCircles {
-			fmt.Printf(
-				"all: %d, saved: %d, new: %d\n",
-				len(circles),
-				len(circleDetailMap)+i,
-				len(filteredCircles)-i,
-			)
+		// errors are reported after the crawler is shut down
+		failed := false
+		if err := runCrawl(crawler, opts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
 
-			circleDetail, err := crawler.FetchCircleDetail(context.Background(), circle)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "failed to fetch circle detail information: %v", err)
-				os.Exit(1)
-			}
-			fmt.Printf("%#v\n", circleDetail)
-			if err := circleCSV.AppendCircleDetail(circleDetail); err != nil {
-				panic(err)
+		if replayCrawler, ok := crawler.(*crawl.ReplayCrawler); ok {
+			if err := reportReplayMismatches(replayCrawler); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed = true
 			}
-			time.Sleep(sleep)
 		}
 
 		// shutdown chrome
-		if crawler.Shutdown(context.Background()); err != nil {
-			fmt.Fprintf(os.Stderr, "shutdown error: %v", err)
-			os.Exit(1)
+		if err := crawler.Shutdown(context.Background()); err != nil {
+			fmt.Fprintf(os.Stderr, "shutdown error: %v\n", err)
+			failed = true
 		}
 
 		// wait for chrome to finish
 		if err := crawler.Wait(); err != nil {
-			fmt.Fprintf(os.Stderr, "wait error: %v", err)
+			fmt.Fprintf(os.Stderr, "wait error: %v\n", err)
+			failed = true
+		}
+
+		if failed {
 			os.Exit(1)
 		}
 	},
 }
 
+// crawlOptions are the options of the crawl command.
+type crawlOptions struct {
+	csvFilePath string
+	// circlesURL is the URL of the circle list. It is not used if the crawl is resumed.
+	circlesURL string
+	// event is the event to be crawled. It is nil if the circle list is specified by --url.
+	event       *tbf.Event
+	refresh     bool
+	maxAge      time.Duration
+	resume      bool
+	maxAttempts int
+	workers     int
+	backend     string
+	// recordDir is the directory to record the crawl session to. The session is not recorded if it is empty.
+	recordDir string
+	// replayDir is the directory of the recorded crawl session to replay. The real site is crawled if it is empty.
+	replayDir string
+	// images is the store to save circle images to. Images are not saved if it is nil.
+	images *cache.ImageStore
+}
+
+func newCrawlOptions(cmd *cobra.Command) (*crawlOptions, error) {
+	opts := &crawlOptions{
+		csvFilePath: viper.GetString(fileKey),
+		circlesURL:  viper.GetString(urlKey),
+		refresh:     viper.GetBool(refreshKey),
+		maxAge:      viper.GetDuration(maxAgeKey),
+		resume:      viper.GetBool(resumeKey),
+		maxAttempts: viper.GetInt(maxAttemptsKey),
+		workers:     viper.GetInt(workersKey),
+		backend:     viper.GetString(backendKey),
+		recordDir:   viper.GetString(recordKey),
+		replayDir:   viper.GetString(replayKey),
+	}
+
+	if viper.GetBool(imagesKey) {
+		images, err := newImageStore()
+		if err != nil {
+			return nil, err
+		}
+		opts.images = images
+	}
+
+	if opts.replayDir != "" {
+		if opts.recordDir != "" {
+			return nil, fmt.Errorf("--%s and --%s can not be used together", recordKey, replayKey)
+		}
+		if !cmd.Flags().Changed(fileKey) {
+			opts.csvFilePath = filepath.Join(opts.replayDir, "circles.csv")
+		}
+		if opts.circlesURL == "" {
+			session, err := crawl.LoadSession(opts.replayDir)
+			if err != nil {
+				return nil, err
+			}
+			opts.circlesURL = session.CirclesURL
+		}
+	}
+
+	if opts.circlesURL != "" {
+		return opts, nil
+	}
+	event, err := getEvent()
+	if err != nil {
+		return nil, err
+	}
+	opts.event = event
+	opts.circlesURL = event.CircleListURL
+	if opts.circlesURL == "" && !opts.resume {
+		return nil, fmt.Errorf("circle list URL of event %s is unknown. please specify --%s", event.ID, urlKey)
+	}
+	return opts, nil
+}
+
+// newCrawler returns a new crawler of the backend in opts, which replays or records the crawl session if specified.
+func newCrawler(opts *crawlOptions, selectors *crawl.Selectors) (crawl.Crawler, error) {
+	if opts.replayDir != "" {
+		return crawl.NewReplayCrawler(context.Background(), opts.backend, opts.replayDir, selectors)
+	}
+
+	crawler, err := crawl.NewCrawler(context.Background(), opts.backend, tbf.BaseURL, selectors)
+	if err != nil {
+		return nil, err
+	}
+	if opts.recordDir != "" {
+		recorder, err := crawl.NewRecorder(opts.recordDir, opts.circlesURL, opts.backend, selectors.Name)
+		if err != nil {
+			crawler.Shutdown(context.Background())
+			return nil, err
+		}
+		crawler.SetRecorder(recorder)
+	}
+	return crawler, nil
+}
+
+// reportReplayMismatches prints the replayed results which differ from the recorded ones.
+// It returns error if there are some mismatches.
+func reportReplayMismatches(crawler *crawl.ReplayCrawler) error {
+	mismatches := crawler.Mismatches()
+	for _, mismatch := range mismatches {
+		fmt.Fprintln(os.Stderr, mismatch)
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%d results differ from the recording", len(mismatches))
+	}
+	fmt.Println("all results are same as the recording")
+	return nil
+}
+
+// runCrawl crawls circles with crawler and saves them to the csv.
+// It returns error if some circles are failed finally or the progress can not be saved.
+func runCrawl(crawler crawl.Crawler, opts *crawlOptions) error {
+	circleCSV, err := csv.NewCircleCSV(opts.csvFilePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to prepare csv")
+	}
+	if opts.images != nil {
+		if err := upgradeCSVForImages(circleCSV); err != nil {
+			return err
+		}
+	}
+
+	historyFilePath := crawl.FetchHistoryFilePath(opts.csvFilePath)
+	history, err := crawl.LoadFetchHistory(historyFilePath)
+	if err != nil {
+		return err
+	}
+
+	journalFilePath := crawl.JournalFilePath(opts.csvFilePath)
+	var journal *crawl.Journal
+	if opts.resume {
+		if !csv.IsExist(journalFilePath) {
+			return fmt.Errorf("crawl journal %s is not found. there is no crawl to resume", journalFilePath)
+		}
+		journal, err = crawl.LoadJournal(journalFilePath)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "resume crawl of %s started at %s\n", journal.CirclesURL, formatTime(journal.StartedAt))
+	} else {
+		if csv.IsExist(journalFilePath) {
+			fmt.Fprintf(os.Stderr, "previous crawl journal %s is discarded. use --%s to continue it\n", journalFilePath, resumeKey)
+		}
+		journal, err = newCrawlJournal(crawler, circleCSV, history, journalFilePath, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	booksFilePath := csv.BooksFilePath(opts.csvFilePath)
+	books, err := csv.ReadBooksFile(booksFilePath)
+	if err != nil {
+		return err
+	}
+
+	store := &crawlStore{
+		circleCSV:       circleCSV,
+		history:         history,
+		historyFilePath: historyFilePath,
+		books:           books,
+		booksFilePath:   booksFilePath,
+		images:          opts.images,
+	}
+	return crawlCircleDetails(crawler, opts.workers, store, journal, opts.maxAttempts)
+}
+
+// getCrawlRate returns the number of requests per second.
+// The deprecated --sleep is converted to the rate if it is given instead of --rate.
+func getCrawlRate(cmd *cobra.Command) float64 {
+	if !cmd.Flags().Changed(sleepKey) || cmd.Flags().Changed(rateKey) {
+		return viper.GetFloat64(rateKey)
+	}
+	sleep := viper.GetInt(sleepKey)
+	if sleep <= 0 {
+		return 0
+	}
+	return 1 / float64(sleep)
+}
+
+// newCrawlJournal fetches the circle list and returns a new journal which has the circles to be fetched.
+func newCrawlJournal(crawler crawl.Crawler, circleCSV *csv.CircleCSV, history crawl.FetchHistory, journalFilePath string,
+	opts *crawlOptions) (*crawl.Journal, error) {
+	circleDetails, err := circleCSV.ToCircleDetails()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse csv")
+	}
+	warnCSV(circleCSV)
+
+	// TODO: Add timeout
+	circles, err := crawler.FetchCircles(context.Background(), opts.circlesURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch circle information")
+	}
+	fmt.Printf("%d circles are found on %s\n", len(circles), opts.circlesURL)
+
+	circles, errs := crawl.ValidateCircles(circles)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "skip circle: %v\n", err)
+	}
+	warnUnknownGenres(opts.event, circles)
+
+	var targets []*crawl.RefreshTarget
+	if opts.refresh {
+		targets = crawl.FilterRefreshTargets(circles, circleDetails, history, opts.maxAge, time.Now())
+	} else {
+		circleDetailMap := map[string]*tbf.CircleDetail{}
+		for _, circleDetail := range circleDetails {
+			circleDetailMap[circleDetail.Space] = circleDetail
+		}
+		for _, circle := range crawl.FilterCircles(circles, circleDetailMap) {
+			targets = append(targets, &crawl.RefreshTarget{Circle: circle, Reason: crawl.RefreshReasonNew})
+		}
+	}
+	fmt.Printf("all: %d, saved: %d, to be fetched: %d\n", len(circles), len(circleDetails), len(targets))
+
+	journal := crawl.NewJournal(journalFilePath, opts.circlesURL, opts.refresh, targets)
+	if err := journal.Save(); err != nil {
+		return nil, err
+	}
+	return journal, nil
+}
+
+// warnUnknownGenres prints warnings for circles which have genres unknown to event.
+// Genres are checked only against the known genres if event is nil.
+func warnUnknownGenres(event *tbf.Event, circles []*tbf.Circle) {
+	for _, circle := range circles {
+		if _, err := lookupGenre(event, circle.Genre); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: circle %q (%s) has %v\n", circle.Name, circle.DetailURL, err)
+		}
+	}
+}
+
+// warnDetailTableReport prints warnings for the labels of the circle detail table which do not match the selector profile.
+func warnDetailTableReport(detailURL string, report *crawl.DetailTableReport) {
+	if report == nil {
+		return
+	}
+	if len(report.UnknownLabels) > 0 {
+		fmt.Fprintf(os.Stderr, "warning: circle detail table of %s has unknown labels: %s\n",
+			detailURL, strings.Join(report.UnknownLabels, ", "))
+	}
+	if len(report.MissingFields) > 0 {
+		fmt.Fprintf(os.Stderr, "warning: labels of %s are not found in circle detail table of %s\n",
+			strings.Join(report.MissingFields, ", "), detailURL)
+	}
+}
+
+// crawlStore saves fetched circle details to the csv and their books to the books file, and records when they are fetched.
+// It is safe for concurrent use.
+type crawlStore struct {
+	circleCSV       *csv.CircleCSV
+	history         crawl.FetchHistory
+	historyFilePath string
+	books           []*tbf.Book
+	booksFilePath   string
+	// images is the store to save circle images to. Images are not saved if it is nil.
+	images *cache.ImageStore
+	m      sync.Mutex
+}
+
+func (s *crawlStore) putCircleDetail(circleDetail *tbf.CircleDetail) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	_, err := s.circleCSV.PutCircleDetail(circleDetail)
+	return err
+}
+
+// putBooks replaces the books of the circle on detailURL with books and saves them to the books file.
+func (s *crawlStore) putBooks(detailURL string, books []*tbf.Book) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.books = csv.ReplaceCircleBooks(s.books, detailURL, books)
+	return csv.WriteBooksFile(s.booksFilePath, s.books)
+}
+
+// storeImage saves the image of circleDetail to the image store if it is enabled.
+// Failure is only warned because the circle detail is still valid, and the image can be fetched later by `tbf images fetch`.
+func (s *crawlStore) storeImage(ctx context.Context, circleDetail *tbf.CircleDetail) {
+	if s.images == nil || circleDetail.ImageURL == "" {
+		return
+	}
+	if err := storeCircleImage(ctx, s.images, circleDetail); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to store image of %s: %v\n", circleDetail.DetailURL, err)
+	}
+}
+
+func (s *crawlStore) recordFetch(detailURL string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.history[detailURL] = time.Now()
+	return s.history.Save(s.historyFilePath)
+}
+
+// crawlCircleDetails fetches the details of the queued circles in journal with workers tabs and saves them to store.
+// Circles which fail are retried later up to maxAttempts times, and circles which are skipped are only warned.
+// It returns error if some circles are failed finally or the progress can not be saved.
+func crawlCircleDetails(crawler crawl.Crawler, workers int, store *crawlStore, journal *crawl.Journal, maxAttempts int) error {
+	err := crawler.RunWorkers(context.Background(), workers, func(ctx context.Context, w *crawl.Worker) error {
+		for {
+			entry, err := journal.Next()
+			if err != nil {
+				return err
+			}
+			if entry == nil {
+				return nil
+			}
+
+			count := journal.Count()
+			fmt.Printf(
+				"done: %d, queued: %d, failed: %d, skipped: %d, worker %d: %s (%s, attempt %d)\n",
+				count[crawl.JournalStateDone],
+				count[crawl.JournalStateQueued],
+				count[crawl.JournalStateFailed],
+				count[crawl.JournalStateSkipped],
+				w.ID,
+				entry.Circle.DetailURL,
+				entry.Reason,
+				entry.Attempts,
+			)
+
+			if err := crawlCircleDetail(ctx, w, store, journal, entry, maxAttempts); err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		return errors.Wrapf(err, "crawl is aborted. run `tbf crawl --%s` to continue it", resumeKey)
+	}
+
+	// skipped circles are not retried, so the crawl is completed even if some circles are skipped
+	for _, entry := range journal.SkippedEntries() {
+		fmt.Fprintf(os.Stderr, "warning: %s %s (%s): %s\n", entry.State, entry.Circle.DetailURL, entry.Circle.Name, entry.LastError)
+	}
+
+	failedEntries := journal.FailedEntries()
+	if len(failedEntries) == 0 {
+		return journal.Remove()
+	}
+
+	for _, entry := range failedEntries {
+		fmt.Fprintf(os.Stderr, "%s %s (%s): %s\n", entry.State, entry.Circle.DetailURL, entry.Circle.Name, entry.LastError)
+	}
+	return fmt.Errorf("%d circles could not be fetched. run `tbf crawl --%s` to retry them", len(failedEntries), resumeKey)
+}
+
+// crawlCircleDetail fetches the detail of entry with worker w and records the result in journal.
+// It returns error only if ctx is canceled or the journal or the fetch history can not be saved.
+// The entry is left in flight in that case, so that it is fetched again on resume.
+func crawlCircleDetail(ctx context.Context, w *crawl.Worker, store *crawlStore, journal *crawl.Journal, entry *crawl.JournalEntry, maxAttempts int) error {
+	page, err := w.FetchCircleDetail(ctx, entry.Circle)
+	if page != nil {
+		warnDetailTableReport(entry.Circle.DetailURL, page.Report)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// retrying is pointless because the page does not match the selector profile
+		if _, ok := errors.Cause(err).(*crawl.MissingLabelsError); ok {
+			fmt.Fprintf(os.Stderr, "skip circle detail of %s: %v\n", entry.Circle.DetailURL, err)
+			return journal.Skip(entry, err)
+		}
+		fmt.Fprintf(os.Stderr, "worker %d failed to fetch circle detail information: %v\n", w.ID, err)
+		return journal.Fail(entry, err, maxAttempts)
+	}
+	circleDetail := page.CircleDetail
+	fmt.Printf("%#v\n", circleDetail)
+
+	if _, err := tbf.ParseSpace(circleDetail.Space); err != nil {
+		fmt.Fprintf(os.Stderr, "skip circle detail of %s: %v\n", circleDetail.DetailURL, err)
+		return journal.Skip(entry, err)
+	}
+
+	store.storeImage(ctx, circleDetail)
+
+	// the row is replaced instead of appended, so that a circle which was saved just before
+	// the previous crawl was interrupted is not duplicated on resume
+	if err := store.putCircleDetail(circleDetail); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to save circle detail of %s: %v\n", circleDetail.DetailURL, err)
+		return journal.Fail(entry, err, maxAttempts)
+	}
+
+	// books are not touched if the selector profile has no book selectors
+	if page.Books != nil {
+		if err := store.putBooks(circleDetail.DetailURL, page.Books); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to save books of %s: %v\n", circleDetail.DetailURL, err)
+			return journal.Fail(entry, err, maxAttempts)
+		}
+	}
+
+	if err := store.recordFetch(circleDetail.DetailURL); err != nil {
+		return err
+	}
+	return journal.Done(entry)
+}
+
 func init() {
 	rootCmd.AddCommand(crawlCmd)
 
 	crawlCmd.Flags().StringP(fileKey, "f", "circles.csv", "サークル情報を書き出すcsvファイル名")
 	viper.BindPFlag(fileKey, crawlCmd.Flags().Lookup(fileKey))
 
-	crawlCmd.Flags().StringP(urlKey, "u", "https://techbookfest.org/event/tbf05/circle", "サークル情報を取得するURL")
+	crawlCmd.Flags().StringP(urlKey, "u", "", "サークル情報を取得するURL(デフォルトは--eventで指定したイベントのサークル一覧)")
 	viper.BindPFlag(urlKey, crawlCmd.Flags().Lookup(urlKey))
 
 	crawlCmd.Flags().Int(sleepKey, 10, "スクレイピングのためにHTTPリクエストを送る際のインターバル(秒)")
 	viper.BindPFlag(sleepKey, crawlCmd.Flags().Lookup(sleepKey))
+	crawlCmd.Flags().MarkDeprecated(sleepKey, "use --"+rateKey+" instead")
+
+	crawlCmd.Flags().Int(workersKey, 1, "サークル詳細を並行して取得するタブの数")
+	viper.BindPFlag(workersKey, crawlCmd.Flags().Lookup(workersKey))
+
+	crawlCmd.Flags().Float64(rateKey, 0.1, "すべてのタブを合わせた1秒あたりの最大リクエスト数(0以下の場合は制限しない)")
+	viper.BindPFlag(rateKey, crawlCmd.Flags().Lookup(rateKey))
+
+	crawlCmd.Flags().Duration(jitterKey, 0, "各リクエストの前に加えるランダムな待ち時間の最大値")
+	viper.BindPFlag(jitterKey, crawlCmd.Flags().Lookup(jitterKey))
+
+	crawlCmd.Flags().Bool(refreshKey, false, "取得済みのサークルのうち変更されたものを再取得してcsvを更新する")
+	viper.BindPFlag(refreshKey, crawlCmd.Flags().Lookup(refreshKey))
+
+	crawlCmd.Flags().Duration(maxAgeKey, 0, "--refresh時に、最後の取得からこの時間が経ったサークルを再取得する(0の場合は変更されたサークルのみ)")
+	viper.BindPFlag(maxAgeKey, crawlCmd.Flags().Lookup(maxAgeKey))
+
+	crawlCmd.Flags().Bool(resumeKey, false, "中断したクロールをジャーナルから再開する")
+	viper.BindPFlag(resumeKey, crawlCmd.Flags().Lookup(resumeKey))
+
+	addSelectorsFlag(crawlCmd)
+
+	crawlCmd.Flags().String(backendKey, crawl.BackendChrome, "クローラーのバックエンド("+strings.Join(crawl.Backends, ", ")+")")
+	viper.BindPFlag(backendKey, crawlCmd.Flags().Lookup(backendKey))
+
+	crawlCmd.Flags().Bool(imagesKey, false, "サークルカット画像をダウンロードして保存する")
+	viper.BindPFlag(imagesKey, crawlCmd.Flags().Lookup(imagesKey))
+
+	crawlCmd.Flags().String(recordKey, "", "クロール中に訪れたページと抽出結果を保存するディレクトリ")
+	viper.BindPFlag(recordKey, crawlCmd.Flags().Lookup(recordKey))
+
+	crawlCmd.Flags().String(replayKey, "", "--recordで保存したページを使ってオフラインでクロールするディレクトリ")
+	viper.BindPFlag(replayKey, crawlCmd.Flags().Lookup(replayKey))
+
+	crawlCmd.Flags().Bool(strictKey, false, "サークル詳細の表に必要なラベルが見つからない場合に取得失敗とする")
+	viper.BindPFlag(strictKey, crawlCmd.Flags().Lookup(strictKey))
+
+	crawlCmd.Flags().Int(maxAttemptsKey, 3, "サークル詳細の取得に失敗した場合に試行する最大回数")
+	viper.BindPFlag(maxAttemptsKey, crawlCmd.Flags().Lookup(maxAttemptsKey))
 }
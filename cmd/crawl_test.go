@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mpppk/tbf/crawl"
+	"github.com/mpppk/tbf/csv"
+	"github.com/mpppk/tbf/tbf"
+)
+
+const crawlFixtureDir = "../crawl/testdata/site"
+
+func readCircleDetailMap(t *testing.T, csvFilePath string) map[string]*tbf.CircleDetail {
+	t.Helper()
+	circleCSV, err := csv.NewCircleCSV(csvFilePath)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	circleDetailMap, err := circleCSV.ToCircleDetailMap()
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	return circleDetailMap
+}
+
+func newTestFixtureCrawler(t *testing.T) *crawl.FixtureCrawler {
+	t.Helper()
+	selectors, err := crawl.LoadSelectors(crawl.DefaultSelectorsName)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	crawler, err := crawl.NewFixtureCrawler(crawlFixtureDir, selectors)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	return crawler
+}
+
+func TestRunCrawl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tbf-crawl")
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	csvFilePath := filepath.Join(dir, "circles.csv")
+
+	crawler := newTestFixtureCrawler(t)
+	defer crawler.Shutdown(context.Background())
+
+	opts := &crawlOptions{
+		csvFilePath: csvFilePath,
+		circlesURL:  crawler.URL("/event/tbf05/circle"),
+		maxAttempts: 1,
+		workers:     2,
+	}
+	if err := runCrawl(crawler, opts); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	circleCSV, err := csv.NewCircleCSV(csvFilePath)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	circleDetailMap, err := circleCSV.ToCircleDetailMap()
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if len(circleDetailMap) != 3 {
+		t.Fatalf("3 circles are expected to be saved, but actually %d", len(circleDetailMap))
+	}
+	circleDetail, ok := circleDetailMap["あ02"]
+	if !ok {
+		t.Fatalf("circle detail of あ02 is expected to be saved")
+	}
+	if expected := crawler.URL("/event/tbf05/circle/28360002"); circleDetail.DetailURL != expected {
+		t.Errorf("detail URL of あ02 is expected to be %s, but actually %s", expected, circleDetail.DetailURL)
+	}
+
+	if csv.IsExist(crawl.JournalFilePath(csvFilePath)) {
+		t.Errorf("crawl journal is expected to be removed after the crawl is completed")
+	}
+	history, err := crawl.LoadFetchHistory(crawl.FetchHistoryFilePath(csvFilePath))
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if len(history) != 3 {
+		t.Errorf("fetch history is expected to have 3 circles, but actually %d", len(history))
+	}
+
+	books, err := csv.ReadBooksFile(csv.BooksFilePath(csvFilePath))
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if len(books) != 3 || books[0].Space != "あ01" || books[1].Space != "あ02" || books[1].CircleDetailURL != circleDetail.DetailURL {
+		t.Errorf("3 books are expected to be saved in order of spaces with their circles, but actually %#v", books)
+	}
+
+	// nothing but the circle list is fetched again because all circles are up to date
+	requests := len(crawler.Server.Requests())
+	opts.refresh = true
+	if err := runCrawl(crawler, opts); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if actual := len(crawler.Server.Requests()) - requests; actual != 1 {
+		t.Errorf("only the circle list is expected to be fetched on refresh, but actually %d pages", actual)
+	}
+}
+
+func TestRunCrawl_notFound(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tbf-crawl")
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	csvFilePath := filepath.Join(dir, "circles.csv")
+
+	crawler := newTestFixtureCrawler(t)
+	defer crawler.Shutdown(context.Background())
+
+	opts := &crawlOptions{
+		csvFilePath: csvFilePath,
+		circlesURL:  crawler.URL("/event/tbf99/circle"),
+		maxAttempts: 1,
+		workers:     1,
+	}
+	if err := runCrawl(crawler, opts); err == nil {
+		t.Errorf("runCrawl is expected to be error if the circle list is not found")
+	}
+}
+
+func TestRunCrawl_resumeWithSkipped(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tbf-crawl")
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	csvFilePath := filepath.Join(dir, "circles.csv")
+
+	crawler := newTestFixtureCrawler(t)
+	defer crawler.Shutdown(context.Background())
+
+	// the previous crawl skipped a circle which has an invalid space, and was interrupted before あ02
+	journalFilePath := crawl.JournalFilePath(csvFilePath)
+	targets := []*crawl.RefreshTarget{
+		{Circle: &tbf.Circle{DetailURL: crawler.URL("/event/tbf05/circle/99999999"), Space: "invalid", Name: "skipped"},
+			Reason: crawl.RefreshReasonNew},
+		{Circle: &tbf.Circle{DetailURL: crawler.URL("/event/tbf05/circle/28360002"), Space: "あ02"},
+			Reason: crawl.RefreshReasonNew},
+	}
+	journal := crawl.NewJournal(journalFilePath, crawler.URL("/event/tbf05/circle"), false, targets)
+	entry, err := journal.Next()
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if err := journal.Skip(entry, errors.New("invalid space")); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	opts := &crawlOptions{
+		csvFilePath: csvFilePath,
+		resume:      true,
+		maxAttempts: 1,
+		workers:     1,
+	}
+	if err := runCrawl(crawler, opts); err != nil {
+		t.Fatalf("resumed crawl is expected to be completed even if some circles are skipped, but actually %s", err)
+	}
+	if csv.IsExist(journalFilePath) {
+		t.Errorf("crawl journal is expected to be removed if only skipped circles remain")
+	}
+	if circleDetailMap := readCircleDetailMap(t, csvFilePath); len(circleDetailMap) != 1 {
+		t.Errorf("1 circle is expected to be saved on resume, but actually %d", len(circleDetailMap))
+	}
+}
+
+func TestRunCrawl_replay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tbf-crawl")
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	sessionDir := filepath.Join(dir, "session")
+
+	crawler := newTestFixtureCrawler(t)
+	defer crawler.Shutdown(context.Background())
+	circlesURL := crawler.URL("/event/tbf05/circle")
+	recorder, err := crawl.NewRecorder(sessionDir, circlesURL, crawl.BackendHTTP, crawl.DefaultSelectorsName)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	crawler.SetRecorder(recorder)
+
+	recordOpts := &crawlOptions{
+		csvFilePath: filepath.Join(dir, "recorded.csv"),
+		circlesURL:  circlesURL,
+		maxAttempts: 1,
+		workers:     1,
+	}
+	if err := runCrawl(crawler, recordOpts); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	replayOpts := &crawlOptions{
+		csvFilePath: filepath.Join(dir, "replayed.csv"),
+		circlesURL:  circlesURL,
+		maxAttempts: 1,
+		workers:     2,
+		backend:     crawl.BackendHTTP,
+		replayDir:   sessionDir,
+	}
+	selectors, err := crawl.LoadSelectors(crawl.DefaultSelectorsName)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	replayCrawler, err := newCrawler(replayOpts, selectors)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	defer replayCrawler.Shutdown(context.Background())
+	if err := runCrawl(replayCrawler, replayOpts); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if err := reportReplayMismatches(replayCrawler.(*crawl.ReplayCrawler)); err != nil {
+		t.Errorf("replayed results are expected to be same as the recording, but actually %s", err)
+	}
+
+	// rows are compared as circle details because the row order depends on the number of workers
+	recorded := readCircleDetailMap(t, recordOpts.csvFilePath)
+	replayed := readCircleDetailMap(t, replayOpts.csvFilePath)
+	if len(recorded) == 0 {
+		t.Fatalf("recorded csv is expected to have circles, but actually empty")
+	}
+	if !reflect.DeepEqual(recorded, replayed) {
+		recordedCSV, _ := ioutil.ReadFile(recordOpts.csvFilePath)
+		replayedCSV, _ := ioutil.ReadFile(replayOpts.csvFilePath)
+		t.Errorf("replayed csv is expected to have the same circles as the recorded one\nrecorded:\n%s\nreplayed:\n%s", recordedCSV, replayedCSV)
+	}
+}
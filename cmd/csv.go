@@ -0,0 +1,173 @@
+// Copyright © 2018 mpppk <niboshiporipori@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mpppk/tbf/csv"
+	"github.com/mpppk/tbf/tbf"
+	"github.com/spf13/cobra"
+)
+
+var updateMetaKey = "update-meta"
+
+// csvCmd represents the csv command
+var csvCmd = &cobra.Command{
+	Use:   "csv",
+	Short: "サークル情報csvを操作します",
+}
+
+// csvNormalizeCmd represents the csv normalize command
+var csvNormalizeCmd = &cobra.Command{
+	Use:   "normalize [csv files]",
+	Short: "サークル情報csvを正規化します",
+	Long: `引数として与えられたサークル情報csvのカラムを以下の順に並べ替え、行をスペース順にソートして書き換えます。
+` + strings.Join(tbf.CircleDetailColumns(), ", ") + `
+同じ内容のcsvは常に同じファイルになるため、メタデータ(.json)のダイジェストが再現可能になります。
+同じディレクトリにメタデータファイルが存在する場合は、ダイジェストも更新します。
+ex)
+$ tbf csv normalize data/*.csv
+`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		updateMeta, err := cmd.Flags().GetBool(updateMetaKey)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		failed := false
+		for _, filePath := range args {
+			if !csv.IsExist(filePath) {
+				fmt.Fprintf(os.Stderr, "csv file not found: %s\n", filePath)
+				failed = true
+				continue
+			}
+
+			circleCSV, err := csv.NewCircleCSV(filePath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to load csv from %s: %v\n", filePath, err)
+				failed = true
+				continue
+			}
+
+			if err := circleCSV.Normalize(); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to normalize %s: %v\n", filePath, err)
+				failed = true
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "%s is normalized\n", filePath)
+			warnCSV(circleCSV)
+
+			if updateMeta {
+				if err := updateMetaIfExists(filePath); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					failed = true
+				}
+			}
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// csvRepairURLsCmd represents the csv repair-urls command
+var csvRepairURLsCmd = &cobra.Command{
+	Use:   "repair-urls [csv files]",
+	Short: "サークル情報csvの壊れたURLを修復します",
+	Long: `古いバージョンのtbf crawlで作成されたcsvのDetailURLとImageURLを修復します。
+"https:/techbookfest.org/..."は"https://techbookfest.org/..."に、
+"/assets/..."のような相対URLは技術書典ウェブサイトを基準とした絶対URLに変換されます。
+同じディレクトリにメタデータファイルが存在する場合は、ダイジェストも更新します。
+ex)
+$ tbf csv repair-urls circles.csv
+`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		updateMeta, err := cmd.Flags().GetBool(updateMetaKey)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		failed := false
+		for _, filePath := range args {
+			if !csv.IsExist(filePath) {
+				fmt.Fprintf(os.Stderr, "csv file not found: %s\n", filePath)
+				failed = true
+				continue
+			}
+
+			circleCSV, err := csv.NewCircleCSV(filePath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to load csv from %s: %v\n", filePath, err)
+				failed = true
+				continue
+			}
+
+			repairedNum, err := circleCSV.RepairURLs()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to repair URLs in %s: %v\n", filePath, err)
+				failed = true
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "%d URLs are repaired in %s\n", repairedNum, filePath)
+
+			if updateMeta && repairedNum > 0 {
+				if err := updateMetaIfExists(filePath); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					failed = true
+				}
+			}
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func updateMetaIfExists(csvFilePath string) error {
+	metaFilePath := csv.MetaFilePath(csvFilePath)
+	if !csv.IsExist(metaFilePath) {
+		return nil
+	}
+	meta, err := csv.WriteMeta(csvFilePath, metaFilePath, "", nil)
+	if err != nil {
+		return fmt.Errorf("failed to update meta file %s: %v", metaFilePath, err)
+	}
+	fmt.Fprintf(os.Stderr, "sha256 in %s is updated to %s\n", metaFilePath, meta.SHA256)
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(csvCmd)
+	csvCmd.AddCommand(csvNormalizeCmd)
+	csvCmd.AddCommand(csvRepairURLsCmd)
+
+	csvNormalizeCmd.Flags().Bool(updateMetaKey, true, "メタデータファイルが存在する場合にダイジェストを更新する")
+	csvRepairURLsCmd.Flags().Bool(updateMetaKey, true, "メタデータファイルが存在する場合にダイジェストを更新する")
+}
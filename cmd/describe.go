@@ -25,50 +25,93 @@ import (
 
 	"os"
 
-	"encoding/json"
-
-	"github.com/mpppk/tbf/csv"
 	"github.com/mpppk/tbf/tbf"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
+var booksKey = "books"
+
 // describeCmd represents the describe command
 var describeCmd = &cobra.Command{
 	Use:   "describe",
 	Short: "サークル情報を表示します",
-	Long: `引数として与えられたスペース名のサークル情報をjsonで表示します
+	Long: `引数として与えられたスペース名のサークル情報を1行に1サークルずつjsonで表示します
+スペース名は"あ1"のようにゼロ埋めを省略して指定することもできます
+--outputで他のフォーマットを指定することもできます(tbf list --helpを参照)
+--booksを指定すると、サークルの頒布物も表示します(出力フォーマットはtext, json, ndjson, template=のみ)
 ex)
 $ tbf describe あ01
+$ tbf describe あ01 あ02 --books --output text
 `,
 	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		spaces := args
 
-		source := tbf.NewSource(viper.GetString("source"))
-		circleCSV, err := csv.NewCircleCSV(source.FileName)
+		formatter, err := newFormatter(cmd)
 		if err != nil {
-			panic(err)
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
 
-		circleDetailMap, err := circleCSV.ToCircleDetailMap()
+		withBooks, err := cmd.Flags().GetBool(booksKey)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		output, err := cmd.Flags().GetString(outputKey)
 		if err != nil {
-			panic(err)
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if withBooks {
+			if err := validateOutputWithBooks(output); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+
+		circleDetailMap, err := loadCircleDetailMap(cmd)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		normalizedCircleDetailMap := map[string]*tbf.CircleDetail{}
+		for space, circleDetail := range circleDetailMap {
+			normalizedCircleDetailMap[tbf.NormalizeSpace(space)] = circleDetail
 		}
 
+		var circleDetails []*tbf.CircleDetail
 		for _, space := range spaces {
-			circleDetail, ok := circleDetailMap[space]
+			if _, err := tbf.ParseSpace(space); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+
+			circleDetail, ok := normalizedCircleDetailMap[tbf.NormalizeSpace(space)]
 			if !ok {
 				fmt.Fprintf(os.Stderr, "circle on %s not found\n", space)
 				continue
 			}
+			circleDetails = append(circleDetails, circleDetail)
+		}
 
-			marshaledCircleDetail, err := json.Marshal(circleDetail)
+		if withBooks {
+			books, err := loadBooks(cmd)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "failed to convert to json from circle detail(%s): %s\n", circleDetail, err)
-				continue
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
 			}
-			fmt.Println(string(marshaledCircleDetail))
+			if err := writeCircleDetailsWithBooks(os.Stdout, formatter, output, circleDetails, tbf.GroupBooksByCircle(books)); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if err := formatter.Format(os.Stdout, circleDetails); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
 	},
 }
@@ -76,6 +119,9 @@ $ tbf describe あ01
 func init() {
 	rootCmd.AddCommand(describeCmd)
 
-	describeCmd.Flags().StringP("source", "s", "latest", "表示するサークル情報のソース(ファイルパスorURLorエイリアス)")
-	viper.BindPFlag("source", listCmd.Flags().Lookup("source"))
+	addSourceFlag(describeCmd)
+
+	addOutputFlag(describeCmd, "ndjson")
+
+	describeCmd.Flags().Bool(booksKey, false, "サークルの頒布物も表示する")
 }
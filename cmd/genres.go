@@ -0,0 +1,77 @@
+// Copyright © 2018 mpppk <niboshiporipori@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mpppk/tbf/tbf"
+	"github.com/spf13/cobra"
+)
+
+// genresCmd represents the genres command
+var genresCmd = &cobra.Command{
+	Use:   "genres",
+	Short: "既知のジャンルの一覧を表示します",
+	Long: `tbf list --genreに指定できるジャンルの一覧を表示します。
+ジャンルはIDと各言語のラベルのどれでも指定できます。EVENTSはそのジャンルを持つイベントです。
+ex)
+$ tbf genres
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tJA\tEN\tEVENTS")
+		for _, genre := range tbf.Genres {
+			var eventIDs []string
+			for _, event := range tbf.Events.Events {
+				if _, err := event.LookupGenre(genre.ID); err == nil {
+					eventIDs = append(eventIDs, event.ID)
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", genre.ID, genre.Label("ja"), genre.Label("en"), strings.Join(eventIDs, ","))
+		}
+		if err := w.Flush(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+// lookupGenre returns the genre which has name as ID or label.
+// If event is not nil, it returns error also if the genre is not a genre of the event.
+func lookupGenre(event *tbf.Event, name string) (*tbf.Genre, error) {
+	if event != nil {
+		return event.LookupGenre(name)
+	}
+	genre, ok := tbf.LookupGenre(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown genre %q", name)
+	}
+	return genre, nil
+}
+
+func init() {
+	rootCmd.AddCommand(genresCmd)
+}
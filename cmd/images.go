@@ -0,0 +1,149 @@
+// Copyright © 2018 mpppk <niboshiporipori@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/mpppk/tbf/cache"
+	"github.com/mpppk/tbf/csv"
+	"github.com/mpppk/tbf/tbf"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// imagesCmd represents the images command
+var imagesCmd = &cobra.Command{
+	Use:   "images",
+	Short: "サークルカット画像を操作します",
+	Long: `サークルカット画像は、画像のSHA-256ハッシュをファイル名として画像の保存先ディレクトリに保存されます。
+保存先は[キャッシュディレクトリ]/imagesで、--image-dirで変更できます。
+tbf listやtbf describeは、保存済みの画像のローカルパスをImagePathとして表示します。`,
+}
+
+// imagesFetchCmd represents the images fetch command
+var imagesFetchCmd = &cobra.Command{
+	Use:   "fetch",
+	Short: "サークルカット画像をダウンロードして保存します",
+	Long: `--sourceで指定したサークル情報のサークルカット画像をダウンロードし、画像の保存先に保存します。
+保存済みの画像はダウンロードしません。
+ソースがcsvファイルの場合は、保存した画像のハッシュとパスをcsvのImageHash, ImagePathに記録します。
+ex)
+$ tbf images fetch --source circles.csv
+$ tbf images fetch --event tbf05`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		circleCSV, isLocal, err := openSourceCSV(cmd)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		images, err := newImageStore()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		if isLocal {
+			if err := upgradeCSVForImages(circleCSV); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+
+		circleDetails, err := circleCSV.ToCircleDetails()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		warnCSV(circleCSV)
+
+		stored, failed := 0, 0
+		for _, circleDetail := range circleDetails {
+			if circleDetail.ImageURL == "" && circleDetail.ImageHash == "" {
+				continue
+			}
+
+			oldHash, oldPath := circleDetail.ImageHash, circleDetail.ImagePath
+			if err := storeCircleImage(context.Background(), images, circleDetail); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to store image of %s (%s): %v\n", circleDetail.Space, circleDetail.Name, err)
+				failed++
+				continue
+			}
+			stored++
+
+			if !isLocal || (circleDetail.ImageHash == oldHash && circleDetail.ImagePath == oldPath) {
+				continue
+			}
+			if _, err := circleCSV.PutCircleDetail(circleDetail); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to record image of %s to %s: %v\n", circleDetail.Space, circleCSV.FilePath(), err)
+				os.Exit(1)
+			}
+		}
+
+		fmt.Printf("%d images are stored in %s\n", stored, images.Dir)
+		if failed > 0 {
+			fmt.Fprintf(os.Stderr, "%d images could not be stored\n", failed)
+			os.Exit(1)
+		}
+	},
+}
+
+// storeCircleImage downloads the image of circleDetail to images unless it is already stored,
+// and records the hash and the path relative to images in circleDetail.
+func storeCircleImage(ctx context.Context, images *cache.ImageStore, circleDetail *tbf.CircleDetail) error {
+	image, ok := images.Get(circleDetail.ImageHash)
+	if !ok {
+		if circleDetail.ImageURL == "" {
+			return fmt.Errorf("image %s is not stored and the circle has no image URL", circleDetail.ImageHash)
+		}
+		var err error
+		image, err = images.Fetch(ctx, circleDetail.ImageURL)
+		if err != nil {
+			return err
+		}
+	}
+	circleDetail.ImageHash = image.Hash
+	circleDetail.ImagePath = image.Path
+	return nil
+}
+
+// upgradeCSVForImages upgrades circleCSV to the current schema if it is older, so that images can be recorded.
+func upgradeCSVForImages(circleCSV *csv.CircleCSV) error {
+	upgraded, err := circleCSV.Upgrade()
+	if err != nil {
+		return errors.Wrap(err, "failed to upgrade csv to record images")
+	}
+	if upgraded {
+		fmt.Fprintf(os.Stderr, "%s is upgraded to schema v%d to record images\n", circleCSV.FilePath(), csv.CurrentSchemaVersion)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(imagesCmd)
+	imagesCmd.AddCommand(imagesFetchCmd)
+
+	addSourceFlag(imagesFetchCmd)
+}
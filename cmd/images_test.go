@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mpppk/tbf/cache"
+	"github.com/mpppk/tbf/csv"
+	"github.com/mpppk/tbf/tbf"
+)
+
+func TestStoreCircleImage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tbf-images")
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cut.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG\r\n\x1a\n"))
+	}))
+	defer server.Close()
+
+	images := cache.NewImageStore(dir)
+	client, err := csv.NewHTTPClient(&csv.HTTPConfig{Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	images.Client = client
+
+	circleDetail := &tbf.CircleDetail{ImageURL: server.URL + "/cut.png"}
+	if err := storeCircleImage(context.Background(), images, circleDetail); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if circleDetail.ImageHash == "" || circleDetail.ImagePath != circleDetail.ImageHash[:2]+"/"+circleDetail.ImageHash+".png" {
+		t.Errorf("hash and relative path of the stored image are expected to be recorded, but actually %#v", circleDetail)
+	}
+
+	// the stored image is served by its hash even after the image URL is expired
+	expired := &tbf.CircleDetail{ImageURL: server.URL + "/expired.png", ImageHash: circleDetail.ImageHash, ImagePath: circleDetail.ImagePath}
+	if err := storeCircleImage(context.Background(), images, expired); err != nil {
+		t.Errorf("Unexpected error occurred when the image is already stored: %s", err)
+	}
+	resolveLocalImage(images, expired)
+	if image, _ := images.Get(circleDetail.ImageHash); expired.ImagePath != images.FilePath(image) {
+		t.Errorf("image path is expected to be resolved to the local file, but actually %s", expired.ImagePath)
+	}
+
+	notStored := &tbf.CircleDetail{ImageURL: server.URL + "/expired.png"}
+	if err := storeCircleImage(context.Background(), images, notStored); err == nil {
+		t.Errorf("storeCircleImage is expected to be error if the image can not be downloaded")
+	}
+	notStored.ImagePath = "ab/abc.png"
+	resolveLocalImage(images, notStored)
+	if notStored.ImagePath != "" {
+		t.Errorf("image path is expected to be cleared if the image is not stored, but actually %s", notStored.ImagePath)
+	}
+}
@@ -23,85 +23,134 @@ package cmd
 import (
 	"fmt"
 
-	"strings"
-
 	"os"
 
-	"github.com/mpppk/tbf/csv"
+	"github.com/mpppk/tbf/query"
 	"github.com/mpppk/tbf/tbf"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+var whereKey = "where"
+var sortKey = "sort"
+var genreKey = "genre"
+
 // listCmd represents the list command
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "与えられたソースのサークル情報を表示",
-	Long: `1行に１サークルの情報を以下のフォーマットで表示します。 
+	Long: `デフォルトでは1行に１サークルの情報を以下のフォーマットで表示します。 
 [スペース名] [サークル名] by [ペンネーム]【[ジャンル名]】 : [頒布物説明]
-ソースにはローカルファイル, URL, エイリアスが使用可能です。
+--outputでtable, json, ndjson, csv, tsv, yaml, markdownやGoのtemplate(template=...)を指定することもできます。
+ソースにはローカルファイル, URL, イベント名が使用可能です。
 ローカルファイルの例: ./circles.csv
-URLの例: https://raw.githubusercontent.com/mpppk/tbf/master/data/latest_circles.csv
-
-次のエイリアスが利用可能です。
-latest(最新の技術書典サークル情報) → https://raw.githubusercontent.com/mpppk/tbf/master/data/latest_circles.csv
-tbf4(技術書典4サークル情報) → https://raw.githubusercontent.com/mpppk/tbf/master/data/tbf4_circles.csv
+URLの例: https://raw.githubusercontent.com/mpppk/tbf/master/data/tbf5_circles.csv
+イベント名の例: tbf05, tbf5, latest(最新の技術書典)
+利用可能なイベントはtbf eventsで確認できます。--eventでイベントを指定することもできます。
+
+--whereを指定すると、条件に一致するサークルのみを表示します。
+フィールド名にはDetailURL, Space, Name, Penname, Genre, ImageURL, WebURL, GenreFreeFormatが使用可能です。
+演算子: ==(一致), !=(不一致), ~(正規表現に一致), !~(正規表現に不一致), ^=(前方一致), $=(後方一致), *=(部分一致)
+条件は&&, ||, !と括弧で組み合わせることができます。
+ex)
+$ tbf list --where 'Genre == "ソフトウェア全般" && GenreFreeFormat ~ "Go|Rust" && Space ^= "か"'
+
+--sortで表示順を指定できます。space, name, genre, pennameをカンマ区切りで複数指定でき、
+それぞれに:ascまたは:descを付けることで昇順/降順を指定できます(デフォルトは昇順)。
+ex)
+$ tbf list --sort genre,space:desc
+
+--genreを指定すると、指定したジャンルのサークルのみを表示します。
+ジャンルはID(software, hardware, science, other)またはラベル(ソフトウェア全般など)をカンマ区切りで指定できます。
+利用可能なジャンルはtbf genresで確認できます。未知のジャンルのサークルがある場合は警告を表示します。
+ex)
+$ tbf list --genre hardware,science
 `,
 	Run: func(cmd *cobra.Command, args []string) {
-		source := tbf.NewSource(viper.GetString("source"))
-		csvFilePath := source.FileName
-
-		if source.Url != "" {
-			csvURL, ok := tbf.GetCSVURL(source.Url)
-			if !ok {
-				fmt.Fprintln(os.Stderr, "unknown CSV URL: "+source.Url)
-				os.Exit(1)
-			}
-
-			csvMetaURL := strings.Replace(csvURL, ".csv", ".json", 1)
+		q, err := query.Parse(viper.GetString(whereKey))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "invalid where expression:", err)
+			os.Exit(1)
+		}
 
-			downloaded, err := csv.DownloadCSVIfChanged(csvURL, csvMetaURL, csvFilePath)
-			if err != nil {
-				panic(err)
-			}
+		sortKeys, err := tbf.ParseSortKeys(viper.GetString(sortKey))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "invalid sort keys:", err)
+			os.Exit(1)
+		}
 
-			if downloaded {
-				fmt.Fprintf(os.Stderr, "new csv file is downloaded from %s to %s\n", csvURL, csvFilePath)
-			}
-		} else if !csv.IsExist(source.FileName) {
-			fmt.Fprintf(os.Stderr, "csv file not found: %s\n", csvFilePath)
+		genres, err := tbf.ParseGenres(viper.GetString(genreKey))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "invalid genre:", err)
 			os.Exit(1)
 		}
 
-		circleCSV, err := csv.NewCircleCSV(csvFilePath)
+		formatter, err := newFormatter(cmd)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "failed to load csv from: "+source.Url)
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 
-		circleDetailMap, err := circleCSV.ToCircleDetailMap()
+		circleDetailMap, err := loadCircleDetailMap(cmd)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "failed to parse csv from: "+source.Url)
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 
-		for space, circleDetail := range circleDetailMap {
-			fmt.Printf("%s %s by %s 【%s】 : %s\n",
-				space,
-				circleDetail.Name,
-				circleDetail.Penname,
-				circleDetail.Genre,
-				circleDetail.GenreFreeFormat,
-			)
+		// genres are checked against the event only if the source is an event
+		var event *tbf.Event
+		if sourceName, err := getSourceName(cmd); err == nil {
+			event, _ = tbf.Events.Get(sourceName)
+		}
+
+		var circleDetails []*tbf.CircleDetail
+		for _, circleDetail := range circleDetailMap {
+			if _, err := tbf.ParseSpace(circleDetail.Space); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: circle %q has %v\n", circleDetail.Name, err)
+			}
+			if _, err := lookupGenre(event, circleDetail.Genre); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: circle %q has %v\n", circleDetail.Name, err)
+			}
+			if q.Match(circleDetail) && matchGenres(genres, circleDetail.Genre) {
+				circleDetails = append(circleDetails, circleDetail)
+			}
+		}
+
+		tbf.SortCircleDetails(circleDetails, sortKeys)
+
+		if err := formatter.Format(os.Stdout, circleDetails); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
 	},
 }
 
+// matchGenres returns true if genre matches one of genres. It always returns true if genres is empty.
+func matchGenres(genres []*tbf.Genre, genre string) bool {
+	if len(genres) == 0 {
+		return true
+	}
+	for _, g := range genres {
+		if g.Matches(genre) {
+			return true
+		}
+	}
+	return false
+}
+
 func init() {
 	rootCmd.AddCommand(listCmd)
 
-	listCmd.Flags().StringP("source", "s", "latest", "表示するサークル情報のソース(ファイルパスorURLorエイリアス)")
-	viper.BindPFlag("source", listCmd.Flags().Lookup("source"))
+	addSourceFlag(listCmd)
+
+	listCmd.Flags().StringP(whereKey, "w", "", "表示するサークルを絞り込む条件式")
+	viper.BindPFlag(whereKey, listCmd.Flags().Lookup(whereKey))
+
+	listCmd.Flags().String(sortKey, "space", "表示順(space, name, genre, pennameをカンマ区切りで指定。:descで降順)")
+	viper.BindPFlag(sortKey, listCmd.Flags().Lookup(sortKey))
+
+	listCmd.Flags().StringP(genreKey, "g", "", "表示するサークルのジャンル(IDまたはラベルをカンマ区切りで指定)")
+	viper.BindPFlag(genreKey, listCmd.Flags().Lookup(genreKey))
+
+	addOutputFlag(listCmd, "text")
 }
@@ -0,0 +1,96 @@
+// Copyright © 2018 mpppk <niboshiporipori@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mpppk/tbf/csv"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var crawledAtKey = "crawled-at"
+
+// metaCmd represents the meta command
+var metaCmd = &cobra.Command{
+	Use:   "meta",
+	Short: "サークル情報csvのメタデータを操作します",
+}
+
+// metaGenerateCmd represents the meta generate command
+var metaGenerateCmd = &cobra.Command{
+	Use:   "generate [csv files]",
+	Short: "サークル情報csvのメタデータを生成します",
+	Long: `引数として与えられたcsvと同じディレクトリに、拡張子を.jsonに変えたメタデータファイルを書き出します。
+メタデータにはSHA-256ダイジェスト, サイズ, サークル数, イベントID, クロール日時, スキーマバージョンが含まれます。
+古いバージョンのtbfのためにCRC32チェックサムも含まれます。
+イベントIDは--eventで、クロール日時は--crawled-atで指定します。指定しない場合は既存のメタデータファイルの値を引き継ぎます。
+ex)
+$ tbf meta generate --event tbf05 --crawled-at 2018-10-01T00:00:00+09:00 data/tbf5_circles.csv
+`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var eventID string
+		if viper.GetString(eventKey) != "" {
+			event, err := getEvent()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			eventID = event.ID
+		}
+
+		var crawledAt *time.Time
+		if crawledAtStr, _ := cmd.Flags().GetString(crawledAtKey); crawledAtStr != "" {
+			t, err := time.Parse(time.RFC3339, crawledAtStr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "invalid --%s: %v\n", crawledAtKey, err)
+				os.Exit(1)
+			}
+			crawledAt = &t
+		}
+
+		failed := false
+		for _, filePath := range args {
+			meta, err := csv.WriteMeta(filePath, csv.MetaFilePath(filePath), eventID, crawledAt)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to generate meta of %s: %v\n", filePath, err)
+				failed = true
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "%s is generated (rows: %d, sha256: %s)\n", csv.MetaFilePath(filePath), meta.Rows, meta.SHA256)
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(metaCmd)
+	metaCmd.AddCommand(metaGenerateCmd)
+
+	metaGenerateCmd.Flags().String(crawledAtKey, "", "クロール日時(RFC3339形式)")
+}
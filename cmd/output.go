@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"github.com/mpppk/tbf/format"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var outputKey = "output"
+
+// addOutputFlag adds --output flag to cmd.
+// The flag is not bound to viper because each command has its own default format.
+func addOutputFlag(cmd *cobra.Command, defaultOutput string) {
+	cmd.Flags().StringP(outputKey, "o", defaultOutput, format.Usage())
+}
+
+func newFormatter(cmd *cobra.Command) (format.Formatter, error) {
+	output, err := cmd.Flags().GetString(outputKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get output flag")
+	}
+	return format.New(output)
+}
+
+// addBookOutputFlag adds --output flag for books to cmd.
+func addBookOutputFlag(cmd *cobra.Command, defaultOutput string) {
+	cmd.Flags().StringP(outputKey, "o", defaultOutput, format.BookUsage())
+}
+
+func newBookFormatter(cmd *cobra.Command) (format.BookFormatter, error) {
+	output, err := cmd.Flags().GetString(outputKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get output flag")
+	}
+	return format.NewBookFormatter(output)
+}
@@ -25,11 +25,23 @@ import (
 	"os"
 
 	"github.com/mitchellh/go-homedir"
+	"github.com/mpppk/tbf/csv"
+	"github.com/mpppk/tbf/tbf"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var cfgFile string
+var eventKey = "event"
+var eventsFileKey = "events-file"
+var offlineKey = "offline"
+var cacheDirKey = "cache-dir"
+var imageDirKey = "image-dir"
+var httpTimeoutKey = "http-timeout"
+var httpRetriesKey = "http-retries"
+var httpProxyKey = "http-proxy"
+var httpCABundleKey = "http-ca-bundle"
+var httpUserAgentKey = "http-user-agent"
 
 var rootCmd = &cobra.Command{
 	Use:   "tbf",
@@ -49,6 +61,37 @@ func Execute() {
 func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.tbf.yaml)")
+
+	rootCmd.PersistentFlags().StringP(eventKey, "e", "", "対象のイベント(tbf05, tbf5, latestなど。tbf eventsで一覧を表示)")
+	viper.BindPFlag(eventKey, rootCmd.PersistentFlags().Lookup(eventKey))
+
+	rootCmd.PersistentFlags().String(eventsFileKey, "", "組み込みのイベント情報に追加するイベントレジストリファイル(YAML)")
+	viper.BindPFlag(eventsFileKey, rootCmd.PersistentFlags().Lookup(eventsFileKey))
+
+	rootCmd.PersistentFlags().Bool(offlineKey, false, "ネットワークにアクセスせず、キャッシュ済みのcsvのみを利用する")
+	viper.BindPFlag(offlineKey, rootCmd.PersistentFlags().Lookup(offlineKey))
+
+	rootCmd.PersistentFlags().String(cacheDirKey, "", "ダウンロードしたcsvのキャッシュディレクトリ(default is $XDG_CACHE_HOME/tbf)")
+	viper.BindPFlag(cacheDirKey, rootCmd.PersistentFlags().Lookup(cacheDirKey))
+
+	rootCmd.PersistentFlags().String(imageDirKey, "", "サークルカット画像の保存先ディレクトリ(default is [キャッシュディレクトリ]/images)")
+	viper.BindPFlag(imageDirKey, rootCmd.PersistentFlags().Lookup(imageDirKey))
+
+	defaultHTTPConfig := csv.DefaultHTTPConfig()
+	rootCmd.PersistentFlags().Duration(httpTimeoutKey, defaultHTTPConfig.Timeout, "HTTPリクエストのタイムアウト")
+	viper.BindPFlag(httpTimeoutKey, rootCmd.PersistentFlags().Lookup(httpTimeoutKey))
+
+	rootCmd.PersistentFlags().Int(httpRetriesKey, defaultHTTPConfig.Retries, "HTTPリクエストが失敗した場合のリトライ回数")
+	viper.BindPFlag(httpRetriesKey, rootCmd.PersistentFlags().Lookup(httpRetriesKey))
+
+	rootCmd.PersistentFlags().String(httpProxyKey, "", "HTTPプロキシのURL(default is HTTP_PROXY/HTTPS_PROXY)")
+	viper.BindPFlag(httpProxyKey, rootCmd.PersistentFlags().Lookup(httpProxyKey))
+
+	rootCmd.PersistentFlags().String(httpCABundleKey, "", "追加で信頼するCA証明書(PEM)のファイルパス")
+	viper.BindPFlag(httpCABundleKey, rootCmd.PersistentFlags().Lookup(httpCABundleKey))
+
+	rootCmd.PersistentFlags().String(httpUserAgentKey, defaultHTTPConfig.UserAgent, "HTTPリクエストのUser-Agent")
+	viper.BindPFlag(httpUserAgentKey, rootCmd.PersistentFlags().Lookup(httpUserAgentKey))
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -75,4 +118,26 @@ func initConfig() {
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Println("Using config file:", viper.ConfigFileUsed())
 	}
+
+	httpConfig := csv.DefaultHTTPConfig()
+	httpConfig.Timeout = viper.GetDuration(httpTimeoutKey)
+	httpConfig.Retries = viper.GetInt(httpRetriesKey)
+	httpConfig.Proxy = viper.GetString(httpProxyKey)
+	httpConfig.CABundle = viper.GetString(httpCABundleKey)
+	httpConfig.UserAgent = viper.GetString(httpUserAgentKey)
+	httpClient, err := csv.NewHTTPClient(httpConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid HTTP configuration:", err)
+		os.Exit(1)
+	}
+	csv.SetHTTPClient(httpClient)
+
+	if eventsFile := viper.GetString(eventsFileKey); eventsFile != "" {
+		events, err := tbf.LoadEventRegistryFile(eventsFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		tbf.Events = events
+	}
 }
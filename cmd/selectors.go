@@ -0,0 +1,218 @@
+// Copyright © 2018 mpppk <niboshiporipori@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mpppk/tbf/crawl"
+	"github.com/mpppk/tbf/format"
+	"github.com/mpppk/tbf/tbf"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/net/html"
+)
+
+var selectorsKey = "selectors"
+var listHTMLKey = "list"
+var detailHTMLKey = "detail"
+
+// selectorsCmd represents the selectors command
+var selectorsCmd = &cobra.Command{
+	Use:   "selectors",
+	Short: "クローラーが利用するCSSセレクタのプロファイルを操作します",
+}
+
+// selectorsCheckCmd represents the selectors check command
+var selectorsCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "セレクタプロファイルを保存したHTMLで検証します",
+	Long: `--selectorsで指定したセレクタプロファイル(デフォルトは--eventで指定したイベントのプロファイル)で、
+保存したサークル一覧ページ(--list)とサークル詳細ページ(--detail)のHTMLからサークル情報を抽出して表示します。
+抽出できない項目がある場合はエラーになります。ウェブサイトのデザイン変更にプロファイルが追従できているかの確認に利用できます。
+サークル詳細の表にプロファイルにないラベルや見つからないラベルがある場合は警告を表示し、--strictを指定するとエラーになります。
+ex)
+$ tbf selectors check --selectors selectors.yaml --list circles.html --detail circle1.html --detail circle2.html
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		listFilePaths, err := cmd.Flags().GetStringSlice(listHTMLKey)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		detailFilePaths, err := cmd.Flags().GetStringSlice(detailHTMLKey)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if len(listFilePaths) == 0 && len(detailFilePaths) == 0 {
+			fmt.Fprintf(os.Stderr, "--%s or --%s must be specified\n", listHTMLKey, detailHTMLKey)
+			os.Exit(1)
+		}
+
+		event, err := getEvent()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		selectors, err := getSelectors(cmd, event)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("selector profile: %s (version %d)\n", selectors.Name, selectors.Version)
+
+		failed := false
+		for _, filePath := range listFilePaths {
+			if err := checkCircleListHTML(filePath, &selectors.CircleList); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed = true
+			}
+		}
+		strict, err := cmd.Flags().GetBool(strictKey)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		for _, filePath := range detailFilePaths {
+			if err := checkCircleDetailHTML(filePath, &selectors.CircleDetail, strict); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// getSelectors returns the selector profile which is a built-in profile name or a file path.
+// --selectors has priority over selectors in config file, and it has priority over the profile of event.
+// The default profile is returned if none of them is given.
+func getSelectors(cmd *cobra.Command, event *tbf.Event) (*crawl.Selectors, error) {
+	name, err := cmd.Flags().GetString(selectorsKey)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = viper.GetString(selectorsKey)
+	}
+	if name == "" && event != nil {
+		name = event.Selectors
+	}
+	if name == "" {
+		name = crawl.DefaultSelectorsName
+	}
+	return crawl.LoadSelectors(name)
+}
+
+// addSelectorsFlag adds --selectors to cmd.
+// The flag is not bound to viper because it is shared by some commands, so it is resolved by getSelectors instead.
+func addSelectorsFlag(cmd *cobra.Command) {
+	cmd.Flags().String(selectorsKey, "", "セレクタプロファイル(組み込みのプロファイル名またはYAMLファイルのパス)")
+}
+
+func checkCircleListHTML(filePath string, s *crawl.CircleListSelectors) error {
+	doc, err := parseHTMLFile(filePath)
+	if err != nil {
+		return err
+	}
+	circles, err := crawl.ExtractCircles(doc, s)
+	if err != nil {
+		return errors.Wrap(err, "failed to extract circles from "+filePath)
+	}
+
+	fmt.Printf("%s: %d circles are found\n", filePath, len(circles))
+	for _, circle := range circles {
+		fmt.Printf("  %s %s by %s【%s】 %s\n", circle.Space, circle.Name, circle.Penname, circle.Genre, circle.DetailURL)
+		warnInvalidCircle(circle)
+	}
+	return nil
+}
+
+func checkCircleDetailHTML(filePath string, s *crawl.CircleDetailSelectors, strict bool) error {
+	doc, err := parseHTMLFile(filePath)
+	if err != nil {
+		return err
+	}
+	circleDetail, report, err := crawl.ExtractCircleDetail(doc, s, strict)
+	warnDetailTableReport(filePath, report)
+	if err != nil {
+		return errors.Wrap(err, "failed to extract circle detail from "+filePath)
+	}
+
+	fmt.Printf("%s:\n", filePath)
+	m := tbf.CircleDetailToMap(circleDetail)
+	for _, column := range tbf.CircleDetailColumns() {
+		// DetailURL is not on the detail page
+		if column == "DetailURL" {
+			continue
+		}
+		fmt.Printf("  %s: %s\n", column, m[column])
+	}
+	warnInvalidCircle(&circleDetail.Circle)
+
+	books, err := crawl.ExtractBooks(doc, s)
+	if err != nil {
+		return errors.Wrap(err, "failed to extract books from "+filePath)
+	}
+	if books != nil {
+		fmt.Printf("  %d books are found\n", len(books))
+		for _, book := range books {
+			fmt.Printf("    %s\n", format.BookSummary(book))
+			for _, imageURL := range book.ImageURLs {
+				fmt.Printf("      %s\n", imageURL)
+			}
+		}
+	}
+	return nil
+}
+
+func warnInvalidCircle(circle *tbf.Circle) {
+	if _, err := tbf.ParseSpace(circle.Space); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: circle %q has %v\n", circle.Name, err)
+	}
+	if _, ok := tbf.LookupGenre(circle.Genre); !ok {
+		fmt.Fprintf(os.Stderr, "warning: circle %q has unknown genre %q\n", circle.Name, circle.Genre)
+	}
+}
+
+func parseHTMLFile(filePath string) (*html.Node, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open html file")
+	}
+	defer file.Close()
+	return crawl.ParseHTML(file)
+}
+
+func init() {
+	rootCmd.AddCommand(selectorsCmd)
+	selectorsCmd.AddCommand(selectorsCheckCmd)
+
+	addSelectorsFlag(selectorsCheckCmd)
+	selectorsCheckCmd.Flags().StringSlice(listHTMLKey, nil, "検証に使うサークル一覧ページのHTMLファイル")
+	selectorsCheckCmd.Flags().StringSlice(detailHTMLKey, nil, "検証に使うサークル詳細ページのHTMLファイル")
+	selectorsCheckCmd.Flags().Bool(strictKey, false, "サークル詳細の表に必要なラベルが見つからない場合にエラーとする")
+}
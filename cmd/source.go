@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mpppk/tbf/cache"
+	"github.com/mpppk/tbf/csv"
+	"github.com/mpppk/tbf/tbf"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var sourceKey = "source"
+
+// addSourceFlag adds --source flag to cmd.
+// The flag is not bound to viper because viper can hold only one flag per key,
+// so it is resolved by getSourceName instead.
+func addSourceFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP(sourceKey, "s", tbf.LatestEventName, "表示するサークル情報のソース(ファイルパスorURLorイベント名)")
+}
+
+// getEvent returns the event specified by --event. The latest event is returned if --event is not given.
+func getEvent() (*tbf.Event, error) {
+	name := viper.GetString(eventKey)
+	if name == "" {
+		name = tbf.LatestEventName
+	}
+	event, ok := tbf.Events.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown event: %s (see `tbf events`)", name)
+	}
+	return event, nil
+}
+
+// getSourceName returns the source of circle information.
+// --source has priority over --event, and --event has priority over source in config file.
+func getSourceName(cmd *cobra.Command) (string, error) {
+	if cmd.Flags().Changed(sourceKey) {
+		if viper.GetString(eventKey) != "" {
+			return "", errors.New("--source and --event can not be used together")
+		}
+		return cmd.Flags().GetString(sourceKey)
+	}
+
+	if viper.GetString(eventKey) != "" {
+		event, err := getEvent()
+		if err != nil {
+			return "", err
+		}
+		return event.ID, nil
+	}
+
+	if source := viper.GetString(sourceKey); source != "" {
+		return source, nil
+	}
+	return cmd.Flags().GetString(sourceKey)
+}
+
+// newCache returns the cache on the directory specified by --cache-dir or the default cache directory.
+func newCache() (*cache.Cache, error) {
+	if dir := viper.GetString(cacheDirKey); dir != "" {
+		return cache.New(dir), nil
+	}
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return cache.New(dir), nil
+}
+
+// fetchToCache downloads the csv on csvURL to the cache if the remote csv is changed,
+// and returns the cache entry. The network is never accessed if --offline is given.
+func fetchToCache(sourceName, csvURL string) (*cache.Entry, error) {
+	c, err := newCache()
+	if err != nil {
+		return nil, err
+	}
+	entry := c.Entry(csvURL)
+
+	if viper.GetBool(offlineKey) {
+		if !entry.HasCSV() {
+			return nil, fmt.Errorf("csv of %s is not cached yet. run without --%s to download it", sourceName, offlineKey)
+		}
+		return entry, nil
+	}
+
+	if err := entry.Create(); err != nil {
+		return nil, err
+	}
+
+	fetchMeta, err := entry.ReadFetchMeta()
+	if err != nil {
+		fetchMeta = &cache.FetchMeta{}
+	}
+
+	// validators are valid only if the cached csv was downloaded from the same URL
+	var validators *csv.HTTPValidators
+	if fetchMeta.URL == csvURL {
+		validators = &csv.HTTPValidators{ETag: fetchMeta.ETag, LastModified: fetchMeta.LastModified}
+	}
+
+	result, err := csv.DownloadCSVIfChanged(csvURL, csv.MetaURL(csvURL), entry.CSVFilePath(), validators)
+	if err != nil {
+		if !entry.HasCSV() {
+			return nil, errors.Wrap(err, "failed to download csv from "+csvURL)
+		}
+		// the cached csv is never replaced by a broken download, so it can be used as a fallback
+		fmt.Fprintf(os.Stderr, "warning: use cached csv fetched at %s because %v\n", formatTime(fetchMeta.FetchedAt), err)
+		return entry, nil
+	}
+
+	now := time.Now()
+	if result.Downloaded || fetchMeta.FetchedAt.IsZero() {
+		fetchMeta.FetchedAt = now
+	}
+	if result.Validators != nil {
+		fetchMeta.ETag = result.Validators.ETag
+		fetchMeta.LastModified = result.Validators.LastModified
+	}
+	fetchMeta.URL = csvURL
+	fetchMeta.Source = sourceName
+	fetchMeta.CheckedAt = now
+	if err := entry.WriteFetchMeta(fetchMeta); err != nil {
+		return nil, err
+	}
+
+	if result.Downloaded {
+		fmt.Fprintf(os.Stderr, "new csv file is downloaded from %s to %s\n", csvURL, entry.CSVFilePath())
+	}
+	return entry, nil
+}
+
+// openSourceCSV opens the csv of the source of cmd.
+// If the source is URL or event name, the csv is read from the cache and downloaded when the remote csv is changed,
+// and isLocal is false because the cached csv is replaced by the next download.
+func openSourceCSV(cmd *cobra.Command) (circleCSV *csv.CircleCSV, isLocal bool, err error) {
+	sourceName, err := getSourceName(cmd)
+	if err != nil {
+		return nil, false, err
+	}
+
+	source := tbf.NewSource(sourceName)
+	csvFilePath := source.FileName
+
+	if source.Url != "" {
+		entry, err := fetchToCache(sourceName, source.Url)
+		if err != nil {
+			return nil, false, err
+		}
+		csvFilePath = entry.CSVFilePath()
+	} else if !csv.IsExist(csvFilePath) {
+		return nil, false, fmt.Errorf("csv file not found: %s", csvFilePath)
+	}
+
+	circleCSV, err = csv.NewCircleCSV(csvFilePath)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to load csv from "+csvFilePath)
+	}
+	return circleCSV, source.Url == "", nil
+}
+
+// loadCircleDetailMap loads circle details from the source of cmd.
+// If the source is URL or event name, the csv is read from the cache and downloaded when the remote csv is changed.
+// Circle images which are stored locally are resolved to their file paths.
+func loadCircleDetailMap(cmd *cobra.Command) (map[string]*tbf.CircleDetail, error) {
+	circleCSV, _, err := openSourceCSV(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	circleDetailMap, err := circleCSV.ToCircleDetailMap()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse csv from "+circleCSV.FilePath())
+	}
+	warnCSV(circleCSV)
+
+	images, err := newImageStore()
+	if err != nil {
+		return nil, err
+	}
+	for _, circleDetail := range circleDetailMap {
+		resolveLocalImage(images, circleDetail)
+	}
+	return circleDetailMap, nil
+}
+
+// warnCSV prints the warnings which are found by reading circleCSV.
+func warnCSV(circleCSV *csv.CircleCSV) {
+	for _, err := range circleCSV.Warnings() {
+		fmt.Fprintf(os.Stderr, "warning: %s: %v\n", circleCSV.FilePath(), err)
+	}
+}
+
+// newImageStore returns the image store on the directory specified by --image-dir or the image store in the cache.
+func newImageStore() (*cache.ImageStore, error) {
+	if dir := viper.GetString(imageDirKey); dir != "" {
+		return cache.NewImageStore(dir), nil
+	}
+	c, err := newCache()
+	if err != nil {
+		return nil, err
+	}
+	return c.Images(), nil
+}
+
+// resolveLocalImage sets the hash and the local file path of the image of circleDetail if the image is in images.
+// The image is looked up by the recorded hash first, and then by ImageURL.
+// ImagePath is cleared if the image is not stored, because the recorded path is meaningless without the store.
+func resolveLocalImage(images *cache.ImageStore, circleDetail *tbf.CircleDetail) {
+	image, ok := images.Get(circleDetail.ImageHash)
+	if !ok && circleDetail.ImageURL != "" {
+		image, ok = images.Lookup(circleDetail.ImageURL)
+	}
+	if !ok {
+		circleDetail.ImagePath = ""
+		return
+	}
+	circleDetail.ImageHash = image.Hash
+	circleDetail.ImagePath = images.FilePath(image)
+}
+
+// loadBooks loads the books of the circles in the source of cmd.
+// The books file of a local csv is read from its sibling file.
+// If the source is URL or event name, the books file is downloaded to the cache unless --offline is given,
+// and the cached one is used if the download fails. No books are returned if the source has no books file.
+func loadBooks(cmd *cobra.Command) ([]*tbf.Book, error) {
+	sourceName, err := getSourceName(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	source := tbf.NewSource(sourceName)
+	if source.Url == "" {
+		return csv.ReadBooksFile(csv.BooksFilePath(source.FileName))
+	}
+
+	c, err := newCache()
+	if err != nil {
+		return nil, err
+	}
+	entry := c.Entry(source.Url)
+	booksURL := csv.BooksURL(source.Url)
+	if !viper.GetBool(offlineKey) && booksURL != "" {
+		if err := entry.Create(); err != nil {
+			return nil, err
+		}
+		found, err := csv.DownloadBooks(booksURL, entry.BooksFilePath())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: use cached books because %v\n", err)
+		} else if !found {
+			// the books are removed from the remote source, so the cached ones are outdated
+			if err := os.Remove(entry.BooksFilePath()); err != nil && !os.IsNotExist(err) {
+				return nil, errors.Wrap(err, "failed to remove cached books")
+			}
+		}
+	}
+	return csv.ReadBooksFile(entry.BooksFilePath())
+}
@@ -3,6 +3,7 @@ package crawl
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/chromedp/cdproto/cdp"
 	"github.com/mpppk/chromedp"
@@ -77,3 +78,59 @@ func AttributeValueAll(sel interface{}, name string, values *[]string, ok *bool,
 		return nil
 	}, opts...)
 }
+
+// WaitCountStable waits until the number of elements matching sel stops changing for stableFor, and stores the number to count.
+// The page is scrolled to the bottom on every poll so that elements which are loaded on scroll are also counted.
+// It returns error if the number does not become stable within timeout.
+func WaitCountStable(sel string, count *int, interval, stableFor, timeout time.Duration) chromedp.Action {
+	if count == nil {
+		panic("count cannot be nil")
+	}
+
+	expr := fmt.Sprintf(`(function() {
+	window.scrollTo(0, document.body.scrollHeight);
+	return document.querySelectorAll(%q).length;
+})()`, sel)
+
+	return chromedp.ActionFunc(func(ctxt context.Context, h cdp.Executor) error {
+		n, err := waitStableCount(ctxt, func(ctxt context.Context) (int, error) {
+			var n int
+			err := chromedp.Evaluate(expr, &n).Do(ctxt, h)
+			return n, err
+		}, interval, stableFor, timeout)
+		if err != nil {
+			return errors.Wrapf(err, "failed to wait for `%s` to be loaded", sel)
+		}
+		*count = n
+		return nil
+	})
+}
+
+// waitStableCount polls count every interval until it returns the same number for stableFor.
+func waitStableCount(ctxt context.Context, count func(ctxt context.Context) (int, error), interval, stableFor, timeout time.Duration) (int, error) {
+	ctxt, cancel := context.WithTimeout(ctxt, timeout)
+	defer cancel()
+
+	last := -1
+	var stableSince time.Time
+	for {
+		n, err := count(ctxt)
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to count elements")
+		}
+
+		now := time.Now()
+		if n != last {
+			last = n
+			stableSince = now
+		} else if now.Sub(stableSince) >= stableFor {
+			return n, nil
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctxt.Done():
+			return 0, errors.Wrapf(ctxt.Err(), "number of elements did not become stable (last count: %d)", last)
+		}
+	}
+}
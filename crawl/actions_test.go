@@ -0,0 +1,52 @@
+package crawl
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWaitStableCount(t *testing.T) {
+	cases := []struct {
+		counts        []int
+		expectedCount int
+		willBeError   bool
+	}{
+		{counts: []int{3}, expectedCount: 3},
+		{counts: []int{0, 10, 200, 469}, expectedCount: 469},
+		{counts: []int{10, 10, 20, 30, 30}, expectedCount: 30},
+		{counts: nil, willBeError: true},
+	}
+
+	for _, c := range cases {
+		i := 0
+		count := func(ctxt context.Context) (int, error) {
+			// the number keeps increasing after the given counts if willBeError
+			if i >= len(c.counts) {
+				if c.willBeError {
+					i++
+					return i, nil
+				}
+				return c.counts[len(c.counts)-1], nil
+			}
+			n := c.counts[i]
+			i++
+			return n, nil
+		}
+
+		n, err := waitStableCount(context.Background(), count, time.Millisecond, 10*time.Millisecond, 200*time.Millisecond)
+		if c.willBeError {
+			if err == nil {
+				t.Errorf("waitStableCount is expected to be error if counts are %v", c.counts)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error occurred when counts are %v: %s", c.counts, err)
+			continue
+		}
+		if n != c.expectedCount {
+			t.Errorf("count is expected to be %d when counts are %v, but actually %d", c.expectedCount, c.counts, n)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/mpppk/chromedp"
+	"github.com/mpppk/tbf/tbf"
+	"github.com/pkg/errors"
+)
+
+// TBFCrawler is the crawler backend which crawls with chrome.
+type TBFCrawler struct {
+	crawlerBase
+	browser *chromedp.CDP
+}
+
+// NewTBFCrawler starts chrome and returns a new crawler which extracts circle information with selectors.
+func NewTBFCrawler(ctx context.Context, baseURL string, selectors *Selectors) (*TBFCrawler, error) {
+	c, err := chromedp.New(ctx, chromedp.WithLog(log.Printf))
+	if err != nil {
+		return nil, errors.Wrap(err, "chromedep new error:")
+	}
+	return &TBFCrawler{
+		crawlerBase: crawlerBase{baseURL: baseURL, selectors: selectors},
+		browser:     c,
+	}, nil
+}
+
+func (t *TBFCrawler) FetchCircles(ctx context.Context, circlesURL string) ([]*tbf.Circle, error) {
+	if err := t.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	tasks, res := circlesFetchingTasks(circlesURL, &t.selectors.CircleList)
+	var pageHTML string
+	if t.recorder != nil {
+		tasks = append(tasks, chromedp.OuterHTML(documentSelector, &pageHTML, chromedp.ByQuery))
+	}
+	err := t.browser.Run(ctx, tasks)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute circles fetching tasks from "+circlesURL)
+	}
+
+	circles, err := t.toCircles(circlesURL, res)
+	if recordErr := t.record(&RecordedPage{URL: circlesURL, Circles: circles}, pageHTML, err); recordErr != nil {
+		return nil, recordErr
+	}
+	return circles, err
+}
+
+func (t *TBFCrawler) toCircles(circlesURL string, res *circlesTasksResult) ([]*tbf.Circle, error) {
+	circles, err := fetchResultToCircles(res)
+	if err != nil {
+		return nil, errors.Wrap(err, "error occurred after circles are fetched")
+	}
+
+	if err := t.resolveDetailURLs(circlesURL, circles); err != nil {
+		return nil, err
+	}
+	return circles, nil
+}
+
+// FetchCircleDetail fetches the detail and the books of circle on the first tab.
+// If it fails after the page is fetched, the returned page may be non-nil and have only the report.
+func (t *TBFCrawler) FetchCircleDetail(ctx context.Context, circle *tbf.Circle) (*CircleDetailPage, error) {
+	return t.fetchCircleDetail(ctx, t.browser.GetHandlerByIndex(0), circle)
+}
+
+func (t *TBFCrawler) fetchCircleDetail(ctx context.Context, tab cdp.Executor, circle *tbf.Circle) (*CircleDetailPage, error) {
+	detailURL, err := t.detailURL(circle)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	tasks, pageHTML := circlesDetailFetchingTasks(detailURL, &t.selectors.CircleDetail)
+	if err := tasks.Do(ctx, tab); err != nil {
+		return nil, errors.Wrapf(err, "failed to navigate to %s", detailURL)
+	}
+	return t.extractCircleDetail(detailURL, *pageHTML)
+}
+
+// RunWorkers opens tabs so that there are n tabs, and runs work concurrently with a worker on each tab.
+// Requests of all workers share the rate limiter of the crawler.
+// If a work returns error, the context given to the other works is canceled, and the first error is returned.
+func (t *TBFCrawler) RunWorkers(ctx context.Context, n int, work func(ctx context.Context, w *Worker) error) error {
+	if n < 1 {
+		return fmt.Errorf("number of workers must be at least 1, but %d is given", n)
+	}
+
+	for i := len(t.browser.ListTargets()); i < n; i++ {
+		var id string
+		if err := t.browser.Run(ctx, t.browser.NewTarget(&id)); err != nil {
+			return errors.Wrapf(err, "failed to open tab for worker %d", i)
+		}
+	}
+
+	var workers []*Worker
+	for i := 0; i < n; i++ {
+		tab := t.browser.GetHandlerByIndex(i)
+		workers = append(workers, &Worker{
+			ID: i,
+			fetch: func(ctx context.Context, circle *tbf.Circle) (*CircleDetailPage, error) {
+				return t.fetchCircleDetail(ctx, tab, circle)
+			},
+		})
+	}
+	return runWorkers(ctx, workers, work)
+}
+
+func (t *TBFCrawler) Shutdown(ctx context.Context) error {
+	return t.browser.Shutdown(ctx)
+}
+
+func (t *TBFCrawler) Wait() error {
+	return t.browser.Wait()
+}
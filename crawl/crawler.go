@@ -2,58 +2,266 @@ package crawl
 
 import (
 	"context"
-	"log"
-
-	"path"
-
 	"fmt"
+	"strings"
+	"sync"
 
-	"github.com/mpppk/chromedp"
 	"github.com/mpppk/tbf/tbf"
 	"github.com/pkg/errors"
 )
 
-type TBFCrawler struct {
-	browser *chromedp.CDP
-	baseURL string
+// Crawler fetches circle information from techbookfest.org.
+type Crawler interface {
+	// FetchCircles fetches the circles on the circle list page.
+	FetchCircles(ctx context.Context, circlesURL string) ([]*tbf.Circle, error)
+	// FetchCircleDetail fetches the detail and the books of circle.
+	// If it fails after the page is fetched, the returned page may be non-nil and have only the report.
+	FetchCircleDetail(ctx context.Context, circle *tbf.Circle) (*CircleDetailPage, error)
+	// RunWorkers runs work concurrently with n workers.
+	// Requests of all workers share the rate limiter of the crawler.
+	// If a work returns error, the context given to the other works is canceled, and the first error is returned.
+	RunWorkers(ctx context.Context, n int, work func(ctx context.Context, w *Worker) error) error
+	// SetRateLimiter sets the rate limiter which is shared by all requests of the crawler.
+	SetRateLimiter(limiter *RateLimiter)
+	// SetStrict sets whether fetching circle detail fails if the labels of some fields are not found in the detail table.
+	SetStrict(strict bool)
+	// SetRecorder sets the recorder which saves every visited page and the results scraped from it.
+	SetRecorder(recorder *Recorder)
+	// Shutdown releases resources of the crawler.
+	Shutdown(ctx context.Context) error
+	// Wait waits until the crawler is shut down.
+	Wait() error
+}
+
+// CircleDetailPage is the result of fetching a circle detail page.
+type CircleDetailPage struct {
+	CircleDetail *tbf.CircleDetail
+	// Books are the books of the circle. It is nil if the selector profile has no book selectors.
+	Books []*tbf.Book
+	// Report has the labels of the detail table which do not match the selector profile.
+	Report *DetailTableReport
 }
 
-func NewTBFCrawler(ctx context.Context, baseURL string) (*TBFCrawler, error) {
-	c, err := chromedp.New(ctx, chromedp.WithLog(log.Printf))
+// Names of crawler backends.
+const (
+	// BackendChrome crawls with chrome, so that pages which are rendered by JavaScript can be crawled.
+	BackendChrome = "chrome"
+	// BackendHTTP crawls by plain HTTP requests without browser. Pages must be rendered on the server.
+	BackendHTTP = "http"
+)
+
+// Backends are the names of the available crawler backends.
+var Backends = []string{BackendChrome, BackendHTTP}
+
+// NewCrawler returns a new crawler of backend which extracts circle information with selectors.
+func NewCrawler(ctx context.Context, backend, baseURL string, selectors *Selectors) (Crawler, error) {
+	switch backend {
+	case BackendChrome:
+		return NewTBFCrawler(ctx, baseURL, selectors)
+	case BackendHTTP:
+		return NewHTTPCrawler(baseURL, selectors, nil), nil
+	default:
+		return nil, fmt.Errorf("unknown crawler backend %q (available: %v)", backend, Backends)
+	}
+}
+
+// crawlerBase has the state and the processing which are shared by crawler backends.
+type crawlerBase struct {
+	baseURL   string
+	selectors *Selectors
+	limiter   *RateLimiter
+	strict    bool
+	recorder  *Recorder
+}
+
+// SetRateLimiter sets the rate limiter which is shared by all requests of the crawler.
+func (c *crawlerBase) SetRateLimiter(limiter *RateLimiter) {
+	c.limiter = limiter
+}
+
+// SetStrict sets whether fetching circle detail fails if the labels of some fields are not found in the detail table.
+func (c *crawlerBase) SetStrict(strict bool) {
+	c.strict = strict
+}
+
+// SetRecorder sets the recorder which saves every visited page and the results scraped from it.
+// Pages are not recorded if recorder is nil.
+func (c *crawlerBase) SetRecorder(recorder *Recorder) {
+	c.recorder = recorder
+}
+
+// record saves the html of page and cause, which is the error occurred while the results were scraped,
+// if the crawler has a recorder.
+func (c *crawlerBase) record(page *RecordedPage, contents string, cause error) error {
+	if c.recorder == nil {
+		return nil
+	}
+	if cause != nil {
+		page.Error = cause.Error()
+	}
+	return errors.Wrap(c.recorder.Record(page, []byte(contents)), "failed to record "+page.URL)
+}
+
+func (c *crawlerBase) wait(ctx context.Context) error {
+	if c.limiter == nil {
+		return ctx.Err()
+	}
+	return errors.Wrap(c.limiter.Wait(ctx), "failed to wait for rate limiter")
+}
+
+// resolveDetailURLs resolves detail URLs of circles against the circle list page which they are found on.
+func (c *crawlerBase) resolveDetailURLs(circlesURL string, circles []*tbf.Circle) error {
+	for _, circle := range circles {
+		detailURL, err := tbf.ResolveURL(circlesURL, circle.DetailURL)
+		if err != nil {
+			return errors.Wrap(err, "failed to resolve detail URL of "+circle.Name)
+		}
+		circle.DetailURL = detailURL
+	}
+	return nil
+}
+
+// detailURL returns the absolute URL of the circle detail page of circle.
+func (c *crawlerBase) detailURL(circle *tbf.Circle) (string, error) {
+	detailURL, err := tbf.ResolveURL(c.baseURL, circle.DetailURL)
 	if err != nil {
-		return nil, errors.Wrap(err, "chromedep new error:")
+		return "", errors.Wrap(err, "failed to resolve detail URL")
+	}
+	if err := tbf.ValidateURL(detailURL); err != nil {
+		return "", errors.Wrap(err, "invalid detail URL")
 	}
-	return &TBFCrawler{
-		browser: c,
-		baseURL: baseURL,
-	}, nil
+	return detailURL, nil
 }
 
-func (t *TBFCrawler) FetchCircles(ctx context.Context, circlesURL string) ([]*tbf.Circle, error) {
-	tasks, res := circlesFetchingTasks(circlesURL)
-	err := t.browser.Run(ctx, tasks)
+// extractCircles extracts the circles from the html of the circle list page on circlesURL,
+// resolves their detail URLs and records the page.
+func (c *crawlerBase) extractCircles(circlesURL, contents string) ([]*tbf.Circle, error) {
+	circles, err := c.parseCircles(circlesURL, contents)
+	if recordErr := c.record(&RecordedPage{URL: circlesURL, Circles: circles}, contents, err); recordErr != nil {
+		return nil, recordErr
+	}
+	return circles, err
+}
+
+func (c *crawlerBase) parseCircles(circlesURL, contents string) ([]*tbf.Circle, error) {
+	doc, err := ParseHTML(strings.NewReader(contents))
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to execute circles fetching tasks from "+circlesURL)
+		return nil, errors.Wrap(err, "failed to parse "+circlesURL)
+	}
+
+	circles, err := ExtractCircles(doc, &c.selectors.CircleList)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to extract circles from "+circlesURL)
+	}
+
+	if err := c.resolveDetailURLs(circlesURL, circles); err != nil {
+		return nil, err
 	}
+	return circles, nil
+}
 
-	circles, err := fetchResultToCircles(res)
-	return circles, errors.Wrap(err, "error occurred after circles are fetched")
+// extractCircleDetail extracts the circle detail and the books from the html of the circle detail page on detailURL,
+// resolves their URLs and records the page.
+func (c *crawlerBase) extractCircleDetail(detailURL, contents string) (*CircleDetailPage, error) {
+	page, err := c.parseCircleDetail(detailURL, contents)
+	// the results are copied because the caller changes them after they are returned, e.g. stores the circle image
+	recordedPage := &RecordedPage{URL: detailURL, Books: copyBooks(page.Books)}
+	if page.CircleDetail != nil {
+		circleDetail := *page.CircleDetail
+		recordedPage.CircleDetail = &circleDetail
+	}
+	if recordErr := c.record(recordedPage, contents, err); recordErr != nil {
+		return page, recordErr
+	}
+	return page, err
 }
 
-func (t *TBFCrawler) FetchCircleDetail(ctx context.Context, circle *tbf.Circle) (*tbf.CircleDetail, error) {
-	tasks, circleDetail := circlesDetailFetchingTasks(fmt.Sprintf("%s/%s", t.baseURL, circle.DetailURL))
-	if err := t.browser.Run(ctx, tasks); err != nil {
-		return nil, errors.Wrapf(err, "failed to navigate to %s", circle.DetailURL)
+// parseCircleDetail returns the page which has only the report if it fails.
+func (c *crawlerBase) parseCircleDetail(detailURL, contents string) (*CircleDetailPage, error) {
+	page := &CircleDetailPage{}
+	doc, err := ParseHTML(strings.NewReader(contents))
+	if err != nil {
+		return page, errors.Wrap(err, "failed to parse circle detail of "+detailURL)
+	}
+
+	circleDetail, report, err := ExtractCircleDetail(doc, &c.selectors.CircleDetail, c.strict)
+	page.Report = report
+	if err != nil {
+		return page, errors.Wrapf(err, "failed to extract circle detail of %s", detailURL)
+	}
+
+	circleDetail.DetailURL = detailURL
+	if circleDetail.ImageURL != "" {
+		imageURL, err := tbf.ResolveURL(detailURL, circleDetail.ImageURL)
+		if err != nil {
+			return page, errors.Wrap(err, "failed to resolve image URL")
+		}
+		circleDetail.ImageURL = imageURL
+	}
+
+	if err := tbf.ValidateCircleDetailURLs(circleDetail); err != nil {
+		return page, errors.Wrap(err, "fetched circle detail has malformed URL")
+	}
+
+	books, err := ExtractBooks(doc, &c.selectors.CircleDetail)
+	if err != nil {
+		return page, errors.Wrapf(err, "failed to extract books of %s", detailURL)
+	}
+	for _, book := range books {
+		book.CircleDetailURL = detailURL
+		book.Space = circleDetail.Space
+		for i, imageURL := range book.ImageURLs {
+			resolved, err := tbf.ResolveURL(detailURL, imageURL)
+			if err != nil {
+				return page, errors.Wrapf(err, "failed to resolve image URL of book %q", book.Title)
+			}
+			book.ImageURLs[i] = resolved
+		}
 	}
 
-	circleDetail.DetailURL = path.Join(t.baseURL, circle.DetailURL)
-	return circleDetail, nil
+	page.CircleDetail = circleDetail
+	page.Books = books
+	return page, nil
 }
 
-func (t *TBFCrawler) Shutdown(ctx context.Context) error {
-	return t.browser.Shutdown(ctx)
+// fetchFunc fetches the detail and the books of circle.
+type fetchFunc func(ctx context.Context, circle *tbf.Circle) (*CircleDetailPage, error)
+
+// Worker fetches circle details concurrently with other workers.
+type Worker struct {
+	// ID is the index of the worker, starting from 0.
+	ID    int
+	fetch fetchFunc
 }
 
-func (t *TBFCrawler) Wait() error {
-	return t.browser.Wait()
+// FetchCircleDetail fetches the detail and the books of circle.
+// If it fails after the page is fetched, the returned page may be non-nil and have only the report.
+func (w *Worker) FetchCircleDetail(ctx context.Context, circle *tbf.Circle) (*CircleDetailPage, error) {
+	return w.fetch(ctx, circle)
+}
+
+// runWorkers runs work concurrently with each worker.
+// If a work returns error, the context given to the other works is canceled, and the first error is returned.
+func runWorkers(ctx context.Context, workers []*Worker, work func(ctx context.Context, w *Worker) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for _, w := range workers {
+		w := w
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := work(ctx, w); err != nil {
+				once.Do(func() {
+					firstErr = errors.Wrapf(err, "worker %d failed", w.ID)
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
 }
@@ -0,0 +1,329 @@
+package crawl
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/mpppk/tbf/tbf"
+	"github.com/pkg/errors"
+	"golang.org/x/net/html"
+)
+
+// ParseHTML parses an HTML document.
+func ParseHTML(r io.Reader) (*html.Node, error) {
+	doc, err := html.Parse(r)
+	return doc, errors.Wrap(err, "failed to parse html")
+}
+
+// ExtractCircles extracts circles from the document of the circle list page with s.
+// DetailURL of the circles are not resolved.
+func ExtractCircles(doc *html.Node, s *CircleListSelectors) ([]*tbf.Circle, error) {
+	items, err := queryAll(doc, s.Item)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no circle is found by selector `%s`", s.Item)
+	}
+
+	var circles []*tbf.Circle
+	for i, item := range items {
+		circle := &tbf.Circle{}
+		fields := []selectorField{
+			{name: "detail_url", sel: s.DetailURL, value: &circle.DetailURL, attr: "href"},
+			{name: "space", sel: s.Space, value: &circle.Space},
+			{name: "name", sel: s.Name, value: &circle.Name},
+			{name: "penname", sel: s.Penname, value: &circle.Penname},
+			{name: "genre", sel: s.Genre, value: &circle.Genre},
+		}
+		for _, field := range fields {
+			value, err := extractValue(item, field.sel, field.attr)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to extract %s of circle %d", field.name, i)
+			}
+			*field.value = value
+		}
+		circles = append(circles, circle)
+	}
+	return circles, nil
+}
+
+// selectorField is a field of circle information which is extracted by a selector.
+type selectorField struct {
+	name  string
+	sel   string
+	value *string
+	// attr is the attribute to extract. The text of the node is extracted if it is empty.
+	attr     string
+	optional bool
+}
+
+// DetailTableReport reports the rows of the circle detail table which do not match the selector profile.
+type DetailTableReport struct {
+	// UnknownLabels are the labels in the table which are neither read nor ignored by the profile.
+	UnknownLabels []string
+	// MissingFields are the fields whose labels are not found in the table.
+	MissingFields []string
+}
+
+// MissingLabelsError is returned in strict mode if the labels of some fields are not found in the circle detail table.
+type MissingLabelsError struct {
+	Fields []string
+}
+
+func (e *MissingLabelsError) Error() string {
+	return fmt.Sprintf("labels of %s are not found in circle detail table", strings.Join(e.Fields, ", "))
+}
+
+// ExtractCircleDetail extracts the circle detail from the document of the circle detail page with s.
+// DetailURL of the circle detail is left empty, and ImageURL is not resolved.
+// ImageURL is left empty if it is not found, because circles may not have circle cuts.
+//
+// If s has a table (selector profile version 2), the fields in the table are read by row labels,
+// and the returned report has the labels which do not match the profile.
+// Fields whose labels are not found are left empty, or MissingLabelsError is returned if strict is true.
+// Otherwise the fields are read by positional selectors, and WebURL and GenreFreeFormat are left empty if they are not found.
+func ExtractCircleDetail(doc *html.Node, s *CircleDetailSelectors, strict bool) (*tbf.CircleDetail, *DetailTableReport, error) {
+	cards, err := queryAll(doc, s.Card)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(cards) == 0 {
+		return nil, nil, fmt.Errorf("circle detail is not found by selector `%s`", s.Card)
+	}
+	card := cards[0]
+
+	circleDetail := &tbf.CircleDetail{}
+	fields := []selectorField{
+		{name: "image", sel: s.Image, value: &circleDetail.ImageURL, attr: "src", optional: true},
+		{name: "name", sel: s.Name, value: &circleDetail.Name},
+	}
+	if s.Table == nil {
+		fields = append(fields, []selectorField{
+			{name: "space", sel: s.Space, value: &circleDetail.Space},
+			{name: "penname", sel: s.Penname, value: &circleDetail.Penname},
+			{name: "web_url", sel: s.WebURL, value: &circleDetail.WebURL, optional: true},
+			{name: "genre", sel: s.Genre, value: &circleDetail.Genre},
+			{name: "genre_free_format", sel: s.GenreFreeFormat, value: &circleDetail.GenreFreeFormat, optional: true},
+		}...)
+	}
+	for _, field := range fields {
+		value, err := extractValue(card, field.sel, field.attr)
+		if err != nil && !field.optional {
+			return nil, nil, errors.Wrapf(err, "failed to extract %s of circle detail", field.name)
+		}
+		*field.value = value
+	}
+
+	report := &DetailTableReport{}
+	if s.Table != nil {
+		report, err = extractDetailTable(card, s.Table, circleDetail)
+		if err != nil {
+			return nil, nil, err
+		}
+		if strict && len(report.MissingFields) > 0 {
+			return nil, report, &MissingLabelsError{Fields: report.MissingFields}
+		}
+	}
+	return circleDetail, report, nil
+}
+
+// extractDetailTable reads the rows of the circle detail table under card by labels, and stores the values to circleDetail.
+func extractDetailTable(card *html.Node, t *DetailTableSelectors, circleDetail *tbf.CircleDetail) (*DetailTableReport, error) {
+	rows, err := queryAll(card, t.Row)
+	if err != nil {
+		return nil, err
+	}
+
+	var labels []string
+	values := map[string]string{}
+	for _, row := range rows {
+		label, err := extractValue(row, t.Label, "")
+		// rows without label like separators are ignored
+		if err != nil || label == "" {
+			continue
+		}
+		// the value may be empty, for example a circle which has no web site
+		value, _ := extractValue(row, t.Value, "")
+		labels = append(labels, label)
+		values[normalizeLabel(label)] = value
+	}
+
+	fields := []struct {
+		name   string
+		labels []string
+		value  *string
+	}{
+		{name: "space", labels: t.Labels.Space, value: &circleDetail.Space},
+		{name: "penname", labels: t.Labels.Penname, value: &circleDetail.Penname},
+		{name: "web_url", labels: t.Labels.WebURL, value: &circleDetail.WebURL},
+		{name: "genre", labels: t.Labels.Genre, value: &circleDetail.Genre},
+		{name: "genre_free_format", labels: t.Labels.GenreFreeFormat, value: &circleDetail.GenreFreeFormat},
+	}
+
+	report := &DetailTableReport{}
+	knownLabels := map[string]bool{}
+	for _, label := range t.Ignore {
+		knownLabels[normalizeLabel(label)] = true
+	}
+	for _, field := range fields {
+		found := false
+		for _, label := range field.labels {
+			knownLabels[normalizeLabel(label)] = true
+			if value, ok := values[normalizeLabel(label)]; ok && !found {
+				*field.value = value
+				found = true
+			}
+		}
+		if !found {
+			report.MissingFields = append(report.MissingFields, field.name)
+		}
+	}
+
+	for _, label := range labels {
+		if !knownLabels[normalizeLabel(label)] {
+			report.UnknownLabels = append(report.UnknownLabels, label)
+		}
+	}
+	return report, nil
+}
+
+// ExtractBooks extracts the books from the document of the circle detail page with s.
+// It returns nil if s has no book selectors, and an empty slice if the circle has no books.
+// CircleDetailURL and Space of the books are left empty, and ImageURLs are not resolved.
+func ExtractBooks(doc *html.Node, s *CircleDetailSelectors) ([]*tbf.Book, error) {
+	if s.Books == nil {
+		return nil, nil
+	}
+	cards, err := queryAll(doc, s.Card)
+	if err != nil {
+		return nil, err
+	}
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("circle detail is not found by selector `%s`", s.Card)
+	}
+
+	b := s.Books
+	items, err := queryAll(cards[0], b.Item)
+	if err != nil {
+		return nil, err
+	}
+
+	newLabels := b.NewLabels
+	if len(newLabels) == 0 {
+		newLabels = DefaultNewBookLabels
+	}
+
+	books := []*tbf.Book{}
+	for i, item := range items {
+		book := &tbf.Book{}
+		var pages, status string
+		fields := []selectorField{
+			{name: "title", sel: b.Title, value: &book.Title},
+			{name: "price", sel: b.Price, value: &book.PriceText, optional: true},
+			{name: "pages", sel: b.Pages, value: &pages, optional: true},
+			{name: "format", sel: b.Format, value: &book.Format, optional: true},
+			{name: "status", sel: b.Status, value: &status, optional: true},
+			{name: "description", sel: b.Description, value: &book.Description, optional: true},
+		}
+		for _, field := range fields {
+			if field.optional && field.sel == "" {
+				continue
+			}
+			value, err := extractValue(item, field.sel, field.attr)
+			if err != nil && !field.optional {
+				return nil, errors.Wrapf(err, "failed to extract %s of book %d", field.name, i)
+			}
+			*field.value = value
+		}
+
+		book.Price, _ = tbf.ParsePrice(book.PriceText)
+		book.Pages, _ = tbf.ParsePages(pages)
+		book.IsNew = isNewBookStatus(status, newLabels)
+		if b.Image != "" {
+			if book.ImageURLs, err = extractAttrs(item, b.Image, "src"); err != nil {
+				return nil, errors.Wrapf(err, "failed to extract images of book %d", i)
+			}
+		}
+		books = append(books, book)
+	}
+	return books, nil
+}
+
+// isNewBookStatus returns true if status contains one of newLabels.
+func isNewBookStatus(status string, newLabels []string) bool {
+	for _, label := range newLabels {
+		if label != "" && strings.Contains(status, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeLabel trims spaces and a trailing colon of a label of the circle detail table, and folds its case.
+func normalizeLabel(label string) string {
+	label = strings.TrimSpace(label)
+	label = strings.TrimSuffix(strings.TrimSuffix(label, ":"), "：")
+	return strings.ToLower(strings.TrimSpace(label))
+}
+
+// extractValue returns the attribute attr of the first node matching sel under n, or the text of the node if attr is empty.
+func extractValue(n *html.Node, sel, attr string) (string, error) {
+	nodes, err := queryAll(n, sel)
+	if err != nil {
+		return "", err
+	}
+	if len(nodes) == 0 {
+		return "", fmt.Errorf("selector `%s` did not return any nodes", sel)
+	}
+
+	if attr == "" {
+		return strings.TrimSpace(nodeText(nodes[0])), nil
+	}
+	for _, a := range nodes[0].Attr {
+		if a.Key == attr {
+			return strings.TrimSpace(a.Val), nil
+		}
+	}
+	return "", fmt.Errorf("node of selector `%s` does not have attribute %s", sel, attr)
+}
+
+// extractAttrs returns the non-empty attribute attr of all nodes matching sel under n.
+func extractAttrs(n *html.Node, sel, attr string) ([]string, error) {
+	nodes, err := queryAll(n, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	for _, node := range nodes {
+		for _, a := range node.Attr {
+			if a.Key == attr && strings.TrimSpace(a.Val) != "" {
+				values = append(values, strings.TrimSpace(a.Val))
+			}
+		}
+	}
+	return values, nil
+}
+
+func queryAll(n *html.Node, sel string) ([]*html.Node, error) {
+	s, err := cascadia.Compile(sel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid selector `%s`", sel)
+	}
+	return s.MatchAll(n), nil
+}
+
+// nodeText returns the concatenated text of n and its descendants.
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(nodeText(c))
+	}
+	return b.String()
+}
@@ -0,0 +1,215 @@
+package crawl_test
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mpppk/tbf/crawl"
+	"github.com/mpppk/tbf/tbf"
+	"golang.org/x/net/html"
+)
+
+func defaultSelectors(t *testing.T) *crawl.Selectors {
+	t.Helper()
+	selectors, ok := crawl.BuiltinSelectors(crawl.DefaultSelectorsName)
+	if !ok {
+		t.Fatalf("default selector profile is not found")
+	}
+	return selectors
+}
+
+func parseHTMLFile(t *testing.T, filePath string) *html.Node {
+	t.Helper()
+	file, err := os.Open(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	defer file.Close()
+	doc, err := crawl.ParseHTML(file)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	return doc
+}
+
+func TestExtractCircles(t *testing.T) {
+	selectors := defaultSelectors(t)
+	doc := parseHTMLFile(t, "testdata/site/event/tbf05/circle.html")
+
+	circles, err := crawl.ExtractCircles(doc, &selectors.CircleList)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	expectedCircles := []*tbf.Circle{
+		{DetailURL: "/event/tbf05/circle/24830001", Space: "あ01", Name: "毬栗ロマン（イガグリロマン）", Penname: "いっこう", Genre: "ソフトウェア全般"},
+		{DetailURL: "/event/tbf05/circle/28360002", Space: "あ02", Name: "いしだけ（イシダケ）", Penname: "t_ishida,コンドウアヤ", Genre: "ソフトウェア全般"},
+		{DetailURL: "/event/tbf05/circle/43220002", Space: "あ03", Name: "錬金術MeetUp（レンキンジュツミートアップ）", Penname: "alchemist", Genre: "科学技術"},
+	}
+	if len(circles) != len(expectedCircles) {
+		t.Fatalf("%d circles are expected, but actually %d", len(expectedCircles), len(circles))
+	}
+	for i, circle := range circles {
+		if *circle != *expectedCircles[i] {
+			t.Errorf("circle %d is expected to be %#v, but actually %#v", i, expectedCircles[i], circle)
+		}
+	}
+
+	selectors.CircleList.Genre = "p.unknown"
+	if _, err := crawl.ExtractCircles(doc, &selectors.CircleList); err == nil {
+		t.Errorf("ExtractCircles is expected to be error if genre is not found")
+	}
+}
+
+func TestExtractCircleDetail(t *testing.T) {
+	selectors := defaultSelectors(t)
+	doc := parseHTMLFile(t, "testdata/site/event/tbf05/circle/28360002.html")
+
+	circleDetail, report, err := crawl.ExtractCircleDetail(doc, &selectors.CircleDetail, true)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if len(report.UnknownLabels) != 0 || len(report.MissingFields) != 0 {
+		t.Errorf("all labels are expected to match, but actually %#v", report)
+	}
+
+	expected := &tbf.CircleDetail{
+		Circle:          tbf.Circle{Space: "あ02", Name: "いしだけ（イシダケ）", Penname: "t_ishida,コンドウアヤ", Genre: "ソフトウェア全般"},
+		ImageURL:        "https://lh3.googleusercontent.com/DT5P_6OcobmPWDzVnu1loCAt_DDrcmQ8P2Y5hE3RWoRb6Fx-4dcuA7U3oPP3yQyAXr3FzH-6Jc8_iI5Z_1Pp",
+		WebURL:          "http://www.dezapatan.com",
+		GenreFreeFormat: "体系的なプログラミング制作を目指してPHPで緩く解説しています",
+	}
+	if *circleDetail != *expected {
+		t.Errorf("circle detail is expected to be %#v, but actually %#v", expected, circleDetail)
+	}
+
+	emptyDoc, err := crawl.ParseHTML(strings.NewReader("<html><body><p>not found</p></body></html>"))
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if _, _, err := crawl.ExtractCircleDetail(emptyDoc, &selectors.CircleDetail, false); err == nil {
+		t.Errorf("ExtractCircleDetail is expected to be error if circle detail card is not found")
+	}
+}
+
+func TestExtractBooks(t *testing.T) {
+	selectors := defaultSelectors(t)
+	doc := parseHTMLFile(t, "testdata/site/event/tbf05/circle/28360002.html")
+
+	books, err := crawl.ExtractBooks(doc, &selectors.CircleDetail)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	expected := []*tbf.Book{
+		{
+			Title:       "PHPでゆるく学ぶプログラミング",
+			Price:       1000,
+			PriceText:   "1,000円",
+			Pages:       64,
+			Format:      "紙+電子",
+			IsNew:       true,
+			Description: "プログラミングの基礎をPHPで緩く解説しています",
+			ImageURLs:   []string{"/images/books/28360002-1-cover.png", "/images/books/28360002-1-back.png"},
+		},
+		{Title: "デザイナーのためのPHP", Price: 500, PriceText: "500円", Format: "紙"},
+	}
+	if !reflect.DeepEqual(books, expected) {
+		t.Errorf("books are expected to be %#v, but actually %#v", expected, books)
+	}
+
+	doc = parseHTMLFile(t, "testdata/site/event/tbf05/circle/43220002.html")
+	books, err = crawl.ExtractBooks(doc, &selectors.CircleDetail)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if books == nil || len(books) != 0 {
+		t.Errorf("empty books are expected if the circle has no books, but actually %#v", books)
+	}
+
+	selectors.CircleDetail.Books = nil
+	books, err = crawl.ExtractBooks(doc, &selectors.CircleDetail)
+	if err != nil || books != nil {
+		t.Errorf("nil is expected if the selector profile has no book selectors, but actually %#v, %v", books, err)
+	}
+}
+
+const reorderedCircleDetailHTML = `
+<mat-card class="circle-detail-card">
+  <table><tbody>
+    <tr><td>サークル名</td><td><span class="circle-name">name1</span></td></tr>
+    <tr><td>ジャンル</td><td>科学技術</td></tr>
+    <tr><td>頒布予定</td><td>新刊</td></tr>
+    <tr><td> スペース： </td><td>あ01</td></tr>
+    <tr><td>ペンネーム</td><td>penname1</td></tr>
+    <tr><td>Webサイト</td><td></td></tr>
+  </tbody></table>
+</mat-card>
+`
+
+func TestExtractCircleDetail_labels(t *testing.T) {
+	selectors := defaultSelectors(t)
+	doc, err := crawl.ParseHTML(strings.NewReader(reorderedCircleDetailHTML))
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	circleDetail, report, err := crawl.ExtractCircleDetail(doc, &selectors.CircleDetail, false)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	expected := &tbf.CircleDetail{
+		Circle: tbf.Circle{Space: "あ01", Name: "name1", Penname: "penname1", Genre: "科学技術"},
+	}
+	if *circleDetail != *expected {
+		t.Errorf("circle detail is expected to be %#v, but actually %#v", expected, circleDetail)
+	}
+	if len(report.UnknownLabels) != 1 || report.UnknownLabels[0] != "頒布予定" {
+		t.Errorf("unknown labels are expected to be [頒布予定], but actually %v", report.UnknownLabels)
+	}
+	if len(report.MissingFields) != 1 || report.MissingFields[0] != "genre_free_format" {
+		t.Errorf("missing fields are expected to be [genre_free_format], but actually %v", report.MissingFields)
+	}
+
+	_, _, err = crawl.ExtractCircleDetail(doc, &selectors.CircleDetail, true)
+	if _, ok := err.(*crawl.MissingLabelsError); !ok {
+		t.Errorf("MissingLabelsError is expected in strict mode, but actually %v", err)
+	}
+}
+
+func TestExtractCircleDetail_positional(t *testing.T) {
+	selectors, err := crawl.ParseSelectors([]byte(`
+version: 1
+circle_list:
+  item: li.circle-list-item
+  detail_url: a.circle-list-item-link
+  space: span.circle-space-label
+  name: span.circle-name
+  penname: p.circle-list-item-penname
+  genre: p.circle-list-item-genre
+circle_detail:
+  ready: mat-card-content.mat-card-content
+  card: mat-card.circle-detail-card
+  image: div.circle-detail-image>img
+  name: tbody span.circle-name
+  space: tbody tr:nth-of-type(2)>td:nth-of-type(2)
+  penname: tbody tr:nth-of-type(3)>td:nth-of-type(2)
+  web_url: tbody tr:nth-of-type(4)>td:nth-of-type(2) a
+  genre: tbody tr:nth-of-type(5)>td:nth-of-type(2)
+  genre_free_format: tbody tr:nth-of-type(6)>td:nth-of-type(2)
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	doc := parseHTMLFile(t, "testdata/site/event/tbf05/circle/28360002.html")
+
+	circleDetail, _, err := crawl.ExtractCircleDetail(doc, &selectors.CircleDetail, true)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if circleDetail.Space != "あ02" || circleDetail.WebURL != "http://www.dezapatan.com" {
+		t.Errorf("circle detail is expected to be read by positions, but actually %#v", circleDetail)
+	}
+}
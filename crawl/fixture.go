@@ -0,0 +1,106 @@
+package crawl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mpppk/tbf/csv"
+)
+
+// FixtureServer is a local HTTP server which serves saved pages in a directory.
+// The page of URL path /a/b is served from a/b.html (see PageFilePath), or a/b/index.html if it does not exist.
+type FixtureServer struct {
+	*httptest.Server
+	dir string
+
+	m        sync.Mutex
+	requests []string
+}
+
+// NewFixtureServer starts a new FixtureServer which serves the pages in dir.
+// The caller should call Close when finished, to shut it down.
+func NewFixtureServer(dir string) *FixtureServer {
+	f := &FixtureServer{dir: dir}
+	f.Server = httptest.NewServer(f)
+	return f
+}
+
+// ServeHTTP serves the saved page of the request path, or responds 404 if it is not saved.
+func (f *FixtureServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.m.Lock()
+	f.requests = append(f.requests, r.URL.Path)
+	f.m.Unlock()
+
+	filePath := f.FilePath(r.URL.Path)
+	if filePath == "" {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, filePath)
+}
+
+// FilePath returns the path of the saved page of urlPath. It returns empty string if the page is not saved.
+func (f *FixtureServer) FilePath(urlPath string) string {
+	candidates := []string{
+		filepath.Join(f.dir, filepath.FromSlash(PageFilePath(urlPath))),
+		filepath.Join(f.dir, filepath.FromSlash(path.Clean("/"+urlPath)), "index.html"),
+	}
+	for _, filePath := range candidates {
+		if info, err := os.Stat(filePath); err == nil && !info.IsDir() {
+			return filePath
+		}
+	}
+	return ""
+}
+
+// Requests returns the paths which have been requested in order.
+func (f *FixtureServer) Requests() []string {
+	f.m.Lock()
+	defer f.m.Unlock()
+	return append([]string{}, f.requests...)
+}
+
+// FixtureCrawler is the crawler backend which crawls the saved pages in a directory through a local FixtureServer,
+// so that crawling can be tested without network or the real site.
+type FixtureCrawler struct {
+	*HTTPCrawler
+	Server *FixtureServer
+}
+
+// NewFixtureCrawler starts a FixtureServer for the pages in dir and returns a new crawler of the server.
+// Shutdown closes the server.
+func NewFixtureCrawler(dir string, selectors *Selectors) (*FixtureCrawler, error) {
+	client, err := newFixtureHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	server := NewFixtureServer(dir)
+	return &FixtureCrawler{
+		HTTPCrawler: NewHTTPCrawler(server.URL, selectors, client),
+		Server:      server,
+	}, nil
+}
+
+// newFixtureHTTPClient returns a new HTTP client for FixtureServer.
+// Failed requests are not retried because the server is local.
+func newFixtureHTTPClient() (*csv.HTTPClient, error) {
+	return csv.NewHTTPClient(&csv.HTTPConfig{Timeout: 10 * time.Second})
+}
+
+// URL returns the URL of urlPath on the server.
+func (f *FixtureCrawler) URL(urlPath string) string {
+	return f.Server.URL + urlPath
+}
+
+// Shutdown closes the server.
+func (f *FixtureCrawler) Shutdown(ctx context.Context) error {
+	f.Server.Close()
+	return nil
+}
@@ -0,0 +1,107 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/mpppk/tbf/csv"
+	"github.com/mpppk/tbf/tbf"
+	"github.com/pkg/errors"
+)
+
+// HTTPCrawler is the crawler backend which fetches pages by plain HTTP requests and parses the HTML without browser.
+// It works in minimal environments where chrome is not available, but pages must be rendered on the server
+// because JavaScript is not executed.
+type HTTPCrawler struct {
+	crawlerBase
+	client *csv.HTTPClient
+}
+
+// NewHTTPCrawler returns a new crawler which fetches pages with client and extracts circle information with selectors.
+// The HTTP client of the csv package is used if client is nil, so that the HTTP settings are shared.
+func NewHTTPCrawler(baseURL string, selectors *Selectors, client *csv.HTTPClient) *HTTPCrawler {
+	return &HTTPCrawler{
+		crawlerBase: crawlerBase{baseURL: baseURL, selectors: selectors},
+		client:      client,
+	}
+}
+
+func (h *HTTPCrawler) FetchCircles(ctx context.Context, circlesURL string) ([]*tbf.Circle, error) {
+	contents, err := h.fetchHTML(ctx, circlesURL)
+	if err != nil {
+		return nil, err
+	}
+	return h.extractCircles(circlesURL, contents)
+}
+
+func (h *HTTPCrawler) FetchCircleDetail(ctx context.Context, circle *tbf.Circle) (*CircleDetailPage, error) {
+	detailURL, err := h.detailURL(circle)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := h.fetchHTML(ctx, detailURL)
+	if err != nil {
+		return nil, err
+	}
+	return h.extractCircleDetail(detailURL, contents)
+}
+
+// RunWorkers runs work concurrently with n workers which share the HTTP client.
+// Requests of all workers share the rate limiter of the crawler.
+// If a work returns error, the context given to the other works is canceled, and the first error is returned.
+func (h *HTTPCrawler) RunWorkers(ctx context.Context, n int, work func(ctx context.Context, w *Worker) error) error {
+	if n < 1 {
+		return fmt.Errorf("number of workers must be at least 1, but %d is given", n)
+	}
+
+	var workers []*Worker
+	for i := 0; i < n; i++ {
+		workers = append(workers, &Worker{ID: i, fetch: h.FetchCircleDetail})
+	}
+	return runWorkers(ctx, workers, work)
+}
+
+// Shutdown does nothing because HTTPCrawler has no resources to release.
+func (h *HTTPCrawler) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Wait returns immediately because HTTPCrawler has nothing to wait for.
+func (h *HTTPCrawler) Wait() error {
+	return nil
+}
+
+func (h *HTTPCrawler) fetchHTML(ctx context.Context, u string) (string, error) {
+	if err := h.wait(ctx); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create request to "+u)
+	}
+	req = req.WithContext(ctx)
+
+	client := h.client
+	if client == nil {
+		client = csv.GetHTTPClient()
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to fetch "+u)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch %s: %s", u, res.Status)
+	}
+
+	contents, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read "+u)
+	}
+	return string(contents), nil
+}
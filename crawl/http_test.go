@@ -0,0 +1,128 @@
+package crawl_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/mpppk/tbf/crawl"
+	"github.com/mpppk/tbf/tbf"
+)
+
+func newFixtureCrawler(t *testing.T) *crawl.FixtureCrawler {
+	t.Helper()
+	crawler, err := crawl.NewFixtureCrawler("testdata/site", defaultSelectors(t))
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	return crawler
+}
+
+func TestHTTPCrawler(t *testing.T) {
+	crawler := newFixtureCrawler(t)
+	defer crawler.Shutdown(context.Background())
+	ctx := context.Background()
+
+	circles, err := crawler.FetchCircles(ctx, crawler.URL("/event/tbf05/circle"))
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if len(circles) != 3 {
+		t.Fatalf("3 circles are expected, but actually %d", len(circles))
+	}
+	if expected := crawler.URL("/event/tbf05/circle/28360002"); circles[1].DetailURL != expected {
+		t.Errorf("detail URL is expected to be resolved to %s, but actually %s", expected, circles[1].DetailURL)
+	}
+
+	for _, circle := range circles {
+		page, err := crawler.FetchCircleDetail(ctx, circle)
+		if err != nil {
+			t.Errorf("Unexpected error occurred when %s is fetched: %s", circle.DetailURL, err)
+			continue
+		}
+		if page.CircleDetail.Circle != *circle {
+			t.Errorf("circle detail is expected to have %#v, but actually %#v", circle, page.CircleDetail.Circle)
+		}
+		if len(page.Report.UnknownLabels) != 0 || len(page.Report.MissingFields) != 0 {
+			t.Errorf("all labels of %s are expected to match, but actually %#v", circle.DetailURL, page.Report)
+		}
+	}
+
+	page, err := crawler.FetchCircleDetail(ctx, circles[2])
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if expected := crawler.URL("/assets/images/dummy_cut.png"); page.CircleDetail.ImageURL != expected {
+		t.Errorf("image URL is expected to be resolved to %s, but actually %s", expected, page.CircleDetail.ImageURL)
+	}
+
+	page, err = crawler.FetchCircleDetail(ctx, circles[1])
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if len(page.Books) != 2 {
+		t.Fatalf("2 books are expected, but actually %d", len(page.Books))
+	}
+	book := page.Books[0]
+	if book.CircleDetailURL != circles[1].DetailURL || book.Space != "あ02" {
+		t.Errorf("book is expected to be linked to %s (あ02), but actually %s (%s)", circles[1].DetailURL, book.CircleDetailURL, book.Space)
+	}
+	if expected := crawler.URL("/images/books/28360002-1-cover.png"); len(book.ImageURLs) != 2 || book.ImageURLs[0] != expected {
+		t.Errorf("book image URLs are expected to be resolved to %s, but actually %v", expected, book.ImageURLs)
+	}
+
+	if _, err := crawler.FetchCircleDetail(ctx, &tbf.Circle{DetailURL: "/event/tbf05/circle/1"}); err == nil {
+		t.Errorf("FetchCircleDetail is expected to be error if the page is not found")
+	}
+}
+
+func TestHTTPCrawler_RunWorkers(t *testing.T) {
+	crawler := newFixtureCrawler(t)
+	defer crawler.Shutdown(context.Background())
+
+	var m sync.Mutex
+	fetched := map[int]bool{}
+	err := crawler.RunWorkers(context.Background(), 2, func(ctx context.Context, w *crawl.Worker) error {
+		if _, err := w.FetchCircleDetail(ctx, &tbf.Circle{DetailURL: "/event/tbf05/circle/28360002"}); err != nil {
+			return err
+		}
+		m.Lock()
+		defer m.Unlock()
+		fetched[w.ID] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if !fetched[0] || !fetched[1] {
+		t.Errorf("both workers are expected to fetch circle detail, but actually %v", fetched)
+	}
+
+	err = crawler.RunWorkers(context.Background(), 2, func(ctx context.Context, w *crawl.Worker) error {
+		_, err := w.FetchCircleDetail(ctx, &tbf.Circle{DetailURL: "/event/tbf05/circle/1"})
+		return err
+	})
+	if err == nil {
+		t.Errorf("RunWorkers is expected to be error if a worker fails")
+	}
+}
+
+func TestFixtureServer(t *testing.T) {
+	server := crawl.NewFixtureServer("testdata/site")
+	defer server.Close()
+
+	cases := []struct {
+		path     string
+		expected string
+	}{
+		{path: "/event/tbf05/circle", expected: "testdata/site/event/tbf05/circle.html"},
+		{path: "/event/tbf05/circle/28360002", expected: "testdata/site/event/tbf05/circle/28360002.html"},
+		{path: "/event/tbf05/circle/1", expected: ""},
+		{path: "/../../crawler", expected: ""},
+	}
+	for _, c := range cases {
+		if filePath := server.FilePath(c.path); filePath != c.expected {
+			t.Errorf("file path of %s is expected to be %q, but actually %q", c.path, c.expected, filePath)
+		}
+	}
+}
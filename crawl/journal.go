@@ -0,0 +1,217 @@
+package crawl
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mpppk/tbf/fsutil"
+	"github.com/mpppk/tbf/tbf"
+	"github.com/pkg/errors"
+)
+
+// JournalState is the state of a circle in the crawl journal.
+type JournalState string
+
+const (
+	// JournalStateQueued means the detail of the circle is waiting to be fetched.
+	JournalStateQueued JournalState = "queued"
+	// JournalStateInFlight means the detail of the circle is being fetched.
+	JournalStateInFlight JournalState = "in_flight"
+	// JournalStateDone means the detail of the circle is fetched and saved.
+	JournalStateDone JournalState = "done"
+	// JournalStateFailed means fetching the detail of the circle failed more than max attempts.
+	JournalStateFailed JournalState = "failed"
+	// JournalStateSkipped means the fetched detail of the circle is invalid, so retrying it is pointless.
+	JournalStateSkipped JournalState = "skipped"
+)
+
+// JournalEntry is a circle in the crawl journal.
+type JournalEntry struct {
+	Circle    *tbf.Circle   `json:"circle"`
+	Reason    RefreshReason `json:"reason"`
+	State     JournalState  `json:"state"`
+	Attempts  int           `json:"attempts"`
+	LastError string        `json:"last_error,omitempty"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// Journal records the progress of a crawl so that an interrupted crawl can be resumed.
+// It is safe for concurrent use.
+type Journal struct {
+	CirclesURL string          `json:"circles_url"`
+	Refresh    bool            `json:"refresh"`
+	StartedAt  time.Time       `json:"started_at"`
+	Entries    []*JournalEntry `json:"entries"`
+
+	filePath string
+	m        sync.Mutex
+}
+
+// JournalFilePath returns the path of the crawl journal for the csv on csvFilePath.
+func JournalFilePath(csvFilePath string) string {
+	return strings.TrimSuffix(csvFilePath, filepath.Ext(csvFilePath)) + ".journal.json"
+}
+
+// NewJournal returns a new journal on filePath which has targets as queued circles.
+func NewJournal(filePath, circlesURL string, refresh bool, targets []*RefreshTarget) *Journal {
+	now := time.Now()
+	journal := &Journal{
+		CirclesURL: circlesURL,
+		Refresh:    refresh,
+		StartedAt:  now,
+		filePath:   filePath,
+	}
+	for _, target := range targets {
+		journal.Entries = append(journal.Entries, &JournalEntry{
+			Circle:    target.Circle,
+			Reason:    target.Reason,
+			State:     JournalStateQueued,
+			UpdatedAt: now,
+		})
+	}
+	return journal
+}
+
+// LoadJournal reads the journal on filePath.
+// Circles which were in flight or failed when the journal was saved are queued again.
+// Skipped circles are kept as they are, because their details are invalid and fetching them again is pointless.
+func LoadJournal(filePath string) (*Journal, error) {
+	contents, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read crawl journal: "+filePath)
+	}
+
+	journal := &Journal{}
+	if err := json.Unmarshal(contents, journal); err != nil {
+		return nil, errors.Wrap(err, "failed to parse crawl journal: "+filePath)
+	}
+	journal.filePath = filePath
+
+	for _, entry := range journal.Entries {
+		if entry.State == JournalStateInFlight || entry.State == JournalStateFailed {
+			entry.State = JournalStateQueued
+			entry.Attempts = 0
+		}
+	}
+	return journal, nil
+}
+
+// Save writes the journal to its file atomically.
+func (j *Journal) Save() error {
+	j.m.Lock()
+	defer j.m.Unlock()
+	return j.save()
+}
+
+func (j *Journal) save() error {
+	contents, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal crawl journal")
+	}
+
+	return errors.Wrap(fsutil.WriteFileAtomically(j.filePath, contents), "failed to write crawl journal: "+j.filePath)
+}
+
+// Remove removes the journal file.
+func (j *Journal) Remove() error {
+	j.m.Lock()
+	defer j.m.Unlock()
+	if err := os.Remove(j.filePath); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove crawl journal: "+j.filePath)
+	}
+	return nil
+}
+
+// Next marks the next queued circle as in flight, saves the journal and returns the entry.
+// Circles which have been attempted fewer times are returned first, so that failed circles are retried later.
+// It returns nil if no circle is queued.
+func (j *Journal) Next() (*JournalEntry, error) {
+	j.m.Lock()
+	defer j.m.Unlock()
+
+	var next *JournalEntry
+	for _, entry := range j.Entries {
+		if entry.State == JournalStateQueued && (next == nil || entry.Attempts < next.Attempts) {
+			next = entry
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+
+	next.State = JournalStateInFlight
+	next.Attempts++
+	next.UpdatedAt = time.Now()
+	return next, j.save()
+}
+
+// Done marks entry as done and saves the journal.
+func (j *Journal) Done(entry *JournalEntry) error {
+	return j.update(entry, JournalStateDone, nil)
+}
+
+// Skip marks entry as skipped and saves the journal.
+func (j *Journal) Skip(entry *JournalEntry, cause error) error {
+	return j.update(entry, JournalStateSkipped, cause)
+}
+
+// Fail queues entry again if it has been attempted fewer than maxAttempts times, otherwise marks it as failed.
+// It saves the journal.
+func (j *Journal) Fail(entry *JournalEntry, cause error, maxAttempts int) error {
+	if entry.Attempts < maxAttempts {
+		return j.update(entry, JournalStateQueued, cause)
+	}
+	return j.update(entry, JournalStateFailed, cause)
+}
+
+func (j *Journal) update(entry *JournalEntry, state JournalState, cause error) error {
+	j.m.Lock()
+	defer j.m.Unlock()
+
+	entry.State = state
+	entry.LastError = ""
+	if cause != nil {
+		entry.LastError = cause.Error()
+	}
+	entry.UpdatedAt = time.Now()
+	return j.save()
+}
+
+// Count returns the number of circles in each state.
+func (j *Journal) Count() map[JournalState]int {
+	j.m.Lock()
+	defer j.m.Unlock()
+
+	count := map[JournalState]int{}
+	for _, entry := range j.Entries {
+		count[entry.State]++
+	}
+	return count
+}
+
+// FailedEntries returns the entries which are failed. They are retried on resume.
+func (j *Journal) FailedEntries() []*JournalEntry {
+	return j.entries(JournalStateFailed)
+}
+
+// SkippedEntries returns the entries which are skipped. They are not retried on resume.
+func (j *Journal) SkippedEntries() []*JournalEntry {
+	return j.entries(JournalStateSkipped)
+}
+
+func (j *Journal) entries(state JournalState) (entries []*JournalEntry) {
+	j.m.Lock()
+	defer j.m.Unlock()
+
+	for _, entry := range j.Entries {
+		if entry.State == state {
+			entries = append(entries, entry)
+		}
+	}
+	return
+}
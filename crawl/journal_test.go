@@ -0,0 +1,115 @@
+package crawl_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mpppk/tbf/crawl"
+)
+
+func TestJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tbf-crawl-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	journalFilePath := crawl.JournalFilePath(filepath.Join(dir, "circles.csv"))
+
+	targets := []*crawl.RefreshTarget{
+		{Circle: newCircle("1", "あ01", "name1"), Reason: crawl.RefreshReasonNew},
+		{Circle: newCircle("2", "あ02", "name2"), Reason: crawl.RefreshReasonNew},
+		{Circle: newCircle("3", "あ03", "name3"), Reason: crawl.RefreshReasonNew},
+	}
+	journal := crawl.NewJournal(journalFilePath, "https://techbookfest.org/event/tbf05/circle", false, targets)
+
+	// name1 fails once and is retried after the others
+	entry, err := journal.Next()
+	if err != nil || entry.Circle.Name != "name1" {
+		t.Fatalf("first entry is expected to be name1, but actually %#v (%v)", entry, err)
+	}
+	if err := journal.Fail(entry, errors.New("timeout"), 2); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	entry, err = journal.Next()
+	if err != nil || entry.Circle.Name != "name2" {
+		t.Fatalf("failed entry is expected to be retried later, but actually %#v (%v)", entry, err)
+	}
+	if err := journal.Done(entry); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	// the crawl is interrupted while name3 is in flight
+	entry, err = journal.Next()
+	if err != nil || entry.Circle.Name != "name3" {
+		t.Fatalf("third entry is expected to be name3, but actually %#v (%v)", entry, err)
+	}
+
+	resumed, err := crawl.LoadJournal(journalFilePath)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	count := resumed.Count()
+	if count[crawl.JournalStateDone] != 1 || count[crawl.JournalStateQueued] != 2 {
+		t.Errorf("resumed journal is expected to have 1 done and 2 queued circles, but actually %v", count)
+	}
+
+	for i := 0; i < 2; i++ {
+		entry, err := resumed.Next()
+		if err != nil || entry == nil {
+			t.Fatalf("queued entry is expected to be returned, but actually %#v (%v)", entry, err)
+		}
+		if err := resumed.Fail(entry, errors.New("timeout"), 1); err != nil {
+			t.Fatalf("Unexpected error occurred: %s", err)
+		}
+	}
+	if entry, err := resumed.Next(); err != nil || entry != nil {
+		t.Errorf("no entry is expected to be queued after all entries fail, but actually %#v (%v)", entry, err)
+	}
+	if failed := resumed.FailedEntries(); len(failed) != 2 || failed[0].LastError != "timeout" {
+		t.Errorf("2 entries are expected to be failed, but actually %#v", failed)
+	}
+
+	if err := resumed.Remove(); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if _, err := os.Stat(journalFilePath); !os.IsNotExist(err) {
+		t.Errorf("journal file is expected to be removed")
+	}
+}
+
+func TestJournal_skipped(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tbf-crawl-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	journalFilePath := crawl.JournalFilePath(filepath.Join(dir, "circles.csv"))
+
+	targets := []*crawl.RefreshTarget{{Circle: newCircle("1", "invalid", "name1"), Reason: crawl.RefreshReasonNew}}
+	journal := crawl.NewJournal(journalFilePath, "https://techbookfest.org/event/tbf05/circle", false, targets)
+	entry, err := journal.Next()
+	if err != nil || entry == nil {
+		t.Fatalf("queued entry is expected to be returned, but actually %#v (%v)", entry, err)
+	}
+	if err := journal.Skip(entry, errors.New("invalid space")); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	resumed, err := crawl.LoadJournal(journalFilePath)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if entry, err := resumed.Next(); err != nil || entry != nil {
+		t.Errorf("skipped entry is expected not to be queued again, but actually %#v (%v)", entry, err)
+	}
+	if failed := resumed.FailedEntries(); len(failed) != 0 {
+		t.Errorf("skipped entry is expected not to be failed, but actually %#v", failed)
+	}
+	if skipped := resumed.SkippedEntries(); len(skipped) != 1 || skipped[0].LastError != "invalid space" {
+		t.Errorf("1 entry is expected to be skipped, but actually %#v", skipped)
+	}
+}
@@ -0,0 +1,85 @@
+package crawl
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket which limits the rate of requests shared by crawler workers.
+// It is safe for concurrent use.
+type RateLimiter struct {
+	rate   float64
+	burst  float64
+	jitter time.Duration
+
+	m      sync.Mutex
+	tokens float64
+	last   time.Time
+	rand   *rand.Rand
+}
+
+// NewRateLimiter returns a new RateLimiter which allows rate requests per second with bursts of up to burst requests.
+// A random wait in [0, jitter) is added to each request so that requests are not sent at regular intervals.
+// The rate is not limited if rate is zero or less.
+func NewRateLimiter(rate float64, burst int, jitter time.Duration) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		jitter: jitter,
+		tokens: float64(burst),
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Wait blocks until a request is allowed or ctx is done.
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	wait := r.reserve(time.Now())
+	if wait <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token and returns the duration to wait before the request is sent.
+// The token is taken even if it is not available yet, so that waiting requests are served in order.
+func (r *RateLimiter) reserve(now time.Time) time.Duration {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var jitter time.Duration
+	if r.jitter > 0 {
+		jitter = time.Duration(r.rand.Int63n(int64(r.jitter)))
+	}
+	if r.rate <= 0 {
+		return jitter
+	}
+
+	if !r.last.IsZero() && now.After(r.last) {
+		r.tokens += now.Sub(r.last).Seconds() * r.rate
+		if r.tokens > r.burst {
+			r.tokens = r.burst
+		}
+	}
+	if now.After(r.last) {
+		r.last = now
+	}
+
+	r.tokens--
+	if r.tokens >= 0 {
+		return jitter
+	}
+	return time.Duration(-r.tokens/r.rate*float64(time.Second)) + jitter
+}
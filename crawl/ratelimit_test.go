@@ -0,0 +1,74 @@
+package crawl
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_reserve(t *testing.T) {
+	now := time.Date(2018, 10, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		rate     float64
+		burst    int
+		elapsed  []time.Duration
+		expected []time.Duration
+	}{
+		{
+			rate:     2,
+			burst:    1,
+			elapsed:  []time.Duration{0, 0, 0},
+			expected: []time.Duration{0, 500 * time.Millisecond, time.Second},
+		},
+		{
+			rate:     2,
+			burst:    2,
+			elapsed:  []time.Duration{0, 0, 0, 0},
+			expected: []time.Duration{0, 0, 500 * time.Millisecond, time.Second},
+		},
+		{
+			rate:     1,
+			burst:    1,
+			elapsed:  []time.Duration{0, time.Second, 10 * time.Second, 10 * time.Second},
+			expected: []time.Duration{0, 0, 0, time.Second},
+		},
+		{
+			rate:     0,
+			burst:    1,
+			elapsed:  []time.Duration{0, 0, 0},
+			expected: []time.Duration{0, 0, 0},
+		},
+	}
+
+	for _, c := range cases {
+		limiter := NewRateLimiter(c.rate, c.burst, 0)
+		for i, elapsed := range c.elapsed {
+			if wait := limiter.reserve(now.Add(elapsed)); wait != c.expected[i] {
+				t.Errorf("wait of request %d is expected to be %s when rate is %v and burst is %d, but actually %s",
+					i, c.expected[i], c.rate, c.burst, wait)
+			}
+		}
+	}
+}
+
+func TestRateLimiter_reserveJitter(t *testing.T) {
+	limiter := NewRateLimiter(0, 1, time.Second)
+	for i := 0; i < 100; i++ {
+		if wait := limiter.reserve(time.Now()); wait < 0 || wait >= time.Second {
+			t.Fatalf("wait is expected to be in [0s, 1s), but actually %s", wait)
+		}
+	}
+}
+
+func TestRateLimiter_WaitCanceled(t *testing.T) {
+	limiter := NewRateLimiter(0.001, 1, 0)
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.Wait(ctx); err == nil {
+		t.Errorf("Wait is expected to be error if context is canceled")
+	}
+}
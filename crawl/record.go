@@ -0,0 +1,187 @@
+package crawl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mpppk/tbf/fsutil"
+	"github.com/mpppk/tbf/tbf"
+	"github.com/pkg/errors"
+)
+
+// CurrentSessionVersion is the version of the session format which is written by Recorder.
+const CurrentSessionVersion = 1
+
+// SessionFileName is the name of the session file in the directory of a recorded crawl session.
+const SessionFileName = "session.json"
+
+// Session is the manifest of a recorded crawl session.
+// Pages are saved in the directory of the session with the same layout as the URL paths,
+// so that they can be served by FixtureServer.
+type Session struct {
+	Version    int       `json:"version"`
+	CirclesURL string    `json:"circles_url"`
+	Backend    string    `json:"backend"`
+	Selectors  string    `json:"selectors,omitempty"`
+	RecordedAt time.Time `json:"recorded_at"`
+	// Pages are the visited pages in order. A page which is visited several times has only the last visit.
+	Pages []*RecordedPage `json:"pages"`
+}
+
+// RecordedPage is a page which is visited in a crawl session, with the results scraped from it.
+type RecordedPage struct {
+	URL string `json:"url"`
+	// File is the slash separated path of the saved html, relative to the session directory.
+	File         string            `json:"file"`
+	Circles      []*tbf.Circle     `json:"circles,omitempty"`
+	CircleDetail *tbf.CircleDetail `json:"circle_detail,omitempty"`
+	Books        []*tbf.Book       `json:"books,omitempty"`
+	// Error is the error which occurred while the results were scraped.
+	Error string `json:"error,omitempty"`
+}
+
+// copy returns a deep copy of p, so that the recorded results are not changed by the caller after they are recorded.
+func (p *RecordedPage) copy() *RecordedPage {
+	page := *p
+	if p.Circles != nil {
+		page.Circles = make([]*tbf.Circle, len(p.Circles))
+		for i, circle := range p.Circles {
+			c := *circle
+			page.Circles[i] = &c
+		}
+	}
+	if p.CircleDetail != nil {
+		circleDetail := *p.CircleDetail
+		page.CircleDetail = &circleDetail
+	}
+	page.Books = copyBooks(p.Books)
+	return &page
+}
+
+// copyBooks returns a deep copy of books.
+func copyBooks(books []*tbf.Book) []*tbf.Book {
+	if books == nil {
+		return nil
+	}
+	copied := make([]*tbf.Book, len(books))
+	for i, book := range books {
+		b := *book
+		b.ImageURLs = append([]string(nil), book.ImageURLs...)
+		copied[i] = &b
+	}
+	return copied
+}
+
+// Page returns the recorded page of pageURL, or nil if it is not recorded.
+func (s *Session) Page(pageURL string) *RecordedPage {
+	for _, page := range s.Pages {
+		if page.URL == pageURL {
+			return page
+		}
+	}
+	return nil
+}
+
+// LoadSession reads the session file in dir.
+func LoadSession(dir string) (*Session, error) {
+	filePath := filepath.Join(dir, SessionFileName)
+	contents, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read crawl session: "+filePath)
+	}
+
+	session := &Session{}
+	if err := json.Unmarshal(contents, session); err != nil {
+		return nil, errors.Wrap(err, "failed to parse crawl session: "+filePath)
+	}
+	if session.Version > CurrentSessionVersion {
+		return nil, fmt.Errorf("crawl session version %d is not supported (supported version is up to %d). please update tbf",
+			session.Version, CurrentSessionVersion)
+	}
+	return session, nil
+}
+
+// PageFilePath returns the slash separated path of the saved html of the page on urlPath.
+// The page of URL path /a/b is saved to a/b.html, and the page of / is saved to index.html.
+func PageFilePath(urlPath string) string {
+	cleanPath := path.Clean("/" + urlPath)
+	if cleanPath == "/" {
+		return "index.html"
+	}
+	return strings.TrimPrefix(cleanPath, "/") + ".html"
+}
+
+// Recorder saves every page which a crawler visits and the results scraped from it to a directory.
+// It is safe for concurrent use.
+type Recorder struct {
+	dir     string
+	session *Session
+	m       sync.Mutex
+}
+
+// NewRecorder returns a new recorder which saves a crawl session of circlesURL to dir.
+// If dir already has a session of circlesURL, e.g. the crawl is resumed, new pages are added to it.
+func NewRecorder(dir, circlesURL, backend, selectorsName string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "failed to create directory to record crawl session: "+dir)
+	}
+
+	session, err := LoadSession(dir)
+	if err != nil || session.CirclesURL != circlesURL {
+		session = &Session{CirclesURL: circlesURL}
+	}
+	session.Version = CurrentSessionVersion
+	session.Backend = backend
+	session.Selectors = selectorsName
+	session.RecordedAt = time.Now()
+	return &Recorder{dir: dir, session: session}, nil
+}
+
+// Record saves contents as the html of page, adds a copy of page to the session and saves the session file.
+// The session is not affected by the changes of page after it is recorded.
+func (r *Recorder) Record(page *RecordedPage, contents []byte) error {
+	u, err := url.Parse(page.URL)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse URL of recorded page: "+page.URL)
+	}
+	page = page.copy()
+	page.File = PageFilePath(u.Path)
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	filePath := filepath.Join(r.dir, filepath.FromSlash(page.File))
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return errors.Wrap(err, "failed to create directory to record page: "+filePath)
+	}
+	if err := fsutil.WriteFileAtomically(filePath, contents); err != nil {
+		return errors.Wrap(err, "failed to record page: "+filePath)
+	}
+
+	pages := []*RecordedPage{}
+	for _, p := range r.session.Pages {
+		if p.URL != page.URL {
+			pages = append(pages, p)
+		}
+	}
+	r.session.Pages = append(pages, page)
+	return r.save()
+}
+
+func (r *Recorder) save() error {
+	contents, err := json.MarshalIndent(r.session, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal crawl session")
+	}
+
+	filePath := filepath.Join(r.dir, SessionFileName)
+	return errors.Wrap(fsutil.WriteFileAtomically(filePath, contents), "failed to write crawl session: "+filePath)
+}
@@ -0,0 +1,238 @@
+package crawl_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mpppk/tbf/crawl"
+	"github.com/mpppk/tbf/tbf"
+)
+
+func TestPageFilePath(t *testing.T) {
+	cases := []struct {
+		urlPath  string
+		expected string
+	}{
+		{urlPath: "/event/tbf05/circle", expected: "event/tbf05/circle.html"},
+		{urlPath: "/event/tbf05/circle/", expected: "event/tbf05/circle.html"},
+		{urlPath: "/", expected: "index.html"},
+		{urlPath: "", expected: "index.html"},
+		{urlPath: "/../../etc/passwd", expected: "etc/passwd.html"},
+	}
+
+	for _, c := range cases {
+		if actual := crawl.PageFilePath(c.urlPath); actual != c.expected {
+			t.Errorf("page file path of %q is expected to be %q, but actually %q", c.urlPath, c.expected, actual)
+		}
+	}
+}
+
+// recordFixtureSession crawls the fixture site with recording to dir, and returns the fetched circle detail pages.
+func recordFixtureSession(t *testing.T, dir string) []*crawl.CircleDetailPage {
+	t.Helper()
+	crawler := newFixtureCrawler(t)
+	defer crawler.Shutdown(context.Background())
+	ctx := context.Background()
+
+	circlesURL := crawler.URL("/event/tbf05/circle")
+	recorder, err := crawl.NewRecorder(dir, circlesURL, crawl.BackendHTTP, crawl.DefaultSelectorsName)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	crawler.SetRecorder(recorder)
+
+	circles, err := crawler.FetchCircles(ctx, circlesURL)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	var pages []*crawl.CircleDetailPage
+	for _, circle := range circles {
+		page, err := crawler.FetchCircleDetail(ctx, circle)
+		if err != nil {
+			t.Fatalf("Unexpected error occurred: %s", err)
+		}
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+func TestRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tbf-record")
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	detailPages := recordFixtureSession(t, dir)
+
+	session, err := crawl.LoadSession(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if len(session.Pages) != 4 {
+		t.Fatalf("4 pages are expected to be recorded, but actually %d", len(session.Pages))
+	}
+	if page := session.Page(session.CirclesURL); page == nil || len(page.Circles) != 3 {
+		t.Errorf("circle list page is expected to be recorded with 3 circles, but actually %#v", page)
+	}
+
+	for _, detailPage := range detailPages {
+		circleDetail := detailPage.CircleDetail
+		page := session.Page(circleDetail.DetailURL)
+		if page == nil {
+			t.Errorf("%s is expected to be recorded", circleDetail.DetailURL)
+			continue
+		}
+		if *page.CircleDetail != *circleDetail {
+			t.Errorf("recorded circle detail of %s is expected to be %#v, but actually %#v",
+				circleDetail.DetailURL, circleDetail, page.CircleDetail)
+		}
+		if len(page.Books) != len(detailPage.Books) {
+			t.Errorf("%d books of %s are expected to be recorded, but actually %d",
+				len(detailPage.Books), circleDetail.DetailURL, len(page.Books))
+		}
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(page.File))); err != nil {
+			t.Errorf("html of %s is expected to be saved to %s: %s", circleDetail.DetailURL, page.File, err)
+		}
+	}
+}
+
+func TestRecorder_copy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tbf-record")
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	const detailURL = "https://techbookfest.org/event/tbf05/circle/28360002"
+	recorder, err := crawl.NewRecorder(dir, "https://techbookfest.org/event/tbf05/circle", crawl.BackendHTTP, crawl.DefaultSelectorsName)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	circleDetail := &tbf.CircleDetail{Circle: *newCircle("28360002", "あ02", "name")}
+	circleDetail.DetailURL = detailURL
+	books := []*tbf.Book{{CircleDetailURL: detailURL, Title: "book", ImageURLs: []string{"https://example.com/book.png"}}}
+	if err := recorder.Record(&crawl.RecordedPage{URL: detailURL, CircleDetail: circleDetail, Books: books}, []byte("<html></html>")); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	recorded, err := ioutil.ReadFile(filepath.Join(dir, crawl.SessionFileName))
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	// the caller keeps using the results after they are recorded, e.g. stores the circle image
+	circleDetail.ImageHash = "0123456789abcdef"
+	circleDetail.ImagePath = "01/0123456789abcdef.png"
+	books[0].Title = "changed"
+	books[0].ImageURLs[0] = "https://example.com/changed.png"
+
+	session, err := crawl.LoadSession(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if err := recorder.Record(&crawl.RecordedPage{URL: session.CirclesURL}, []byte("<html></html>")); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	session, err = crawl.LoadSession(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	recordedSession := &crawl.Session{}
+	if err := json.Unmarshal(recorded, recordedSession); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if page := session.Page(detailURL); !reflect.DeepEqual(page, recordedSession.Page(detailURL)) {
+		t.Errorf("recorded page is expected not to be changed after it is recorded, but actually %#v", page)
+	}
+}
+
+func TestReplayCrawler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tbf-replay")
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	recordedPages := recordFixtureSession(t, dir)
+
+	crawler, err := crawl.NewReplayCrawler(context.Background(), crawl.BackendHTTP, dir, defaultSelectors(t))
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	defer crawler.Shutdown(context.Background())
+
+	circles, err := crawler.FetchCircles(context.Background(), crawler.Session.CirclesURL)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	err = crawler.RunWorkers(context.Background(), 2, func(ctx context.Context, w *crawl.Worker) error {
+		for i := w.ID; i < len(circles); i += 2 {
+			page, err := w.FetchCircleDetail(ctx, circles[i])
+			if err != nil {
+				return err
+			}
+			if *page.CircleDetail != *recordedPages[i].CircleDetail {
+				t.Errorf("replayed circle detail is expected to be %#v, but actually %#v", recordedPages[i].CircleDetail, page.CircleDetail)
+			}
+			if !reflect.DeepEqual(page.Books, recordedPages[i].Books) {
+				t.Errorf("replayed books are expected to be %#v, but actually %#v", recordedPages[i].Books, page.Books)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if mismatches := crawler.Mismatches(); len(mismatches) != 0 {
+		t.Errorf("replayed results are expected to be same as the recording, but actually %v", mismatches)
+	}
+	if requests := crawler.Server.Requests(); len(requests) != 4 {
+		t.Errorf("4 pages are expected to be served, but actually %v", requests)
+	}
+}
+
+func TestReplayCrawler_mismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tbf-replay")
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	recordedPages := recordFixtureSession(t, dir)
+
+	selectors := defaultSelectors(t)
+	selectors.CircleDetail.Table.Labels.Penname = []string{"作者"}
+	selectors.CircleDetail.Books.Price = ".unknown"
+	crawler, err := crawl.NewReplayCrawler(context.Background(), crawl.BackendHTTP, dir, selectors)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	defer crawler.Shutdown(context.Background())
+
+	if _, err := crawler.FetchCircleDetail(context.Background(), &recordedPages[0].CircleDetail.Circle); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if mismatches := crawler.Mismatches(); len(mismatches) != 1 {
+		t.Errorf("a mismatch is expected to be reported if penname is lost, but actually %v", mismatches)
+	}
+
+	selectors.CircleDetail.Table.Labels.Penname = []string{"ペンネーム"}
+	if _, err := crawler.FetchCircleDetail(context.Background(), &recordedPages[1].CircleDetail.Circle); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if mismatches := crawler.Mismatches(); len(mismatches) != 2 {
+		t.Errorf("a mismatch is expected to be reported if prices of books are lost, but actually %v", mismatches)
+	}
+
+	if _, err := crawler.FetchCircleDetail(context.Background(), &tbf.Circle{DetailURL: "/event/tbf05/circle/1"}); err == nil {
+		t.Errorf("FetchCircleDetail is expected to be error if the page is not recorded")
+	}
+	if mismatches := crawler.Mismatches(); len(mismatches) != 3 {
+		t.Errorf("a mismatch is expected to be reported if the page is not recorded, but actually %v", mismatches)
+	}
+}
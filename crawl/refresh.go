@@ -0,0 +1,97 @@
+package crawl
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mpppk/tbf/fsutil"
+	"github.com/mpppk/tbf/tbf"
+	"github.com/pkg/errors"
+)
+
+// FetchHistory records when the detail of each circle was fetched, keyed by DetailURL.
+type FetchHistory map[string]time.Time
+
+// FetchHistoryFilePath returns the path of the fetch history file for the csv on csvFilePath.
+func FetchHistoryFilePath(csvFilePath string) string {
+	return strings.TrimSuffix(csvFilePath, filepath.Ext(csvFilePath)) + ".fetched.json"
+}
+
+// LoadFetchHistory reads the fetch history file. Empty history is returned if the file does not exist.
+func LoadFetchHistory(filePath string) (FetchHistory, error) {
+	contents, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return FetchHistory{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read fetch history: "+filePath)
+	}
+
+	history := FetchHistory{}
+	if err := json.Unmarshal(contents, &history); err != nil {
+		return nil, errors.Wrap(err, "failed to parse fetch history: "+filePath)
+	}
+	return history, nil
+}
+
+// Save writes the fetch history to filePath.
+func (h FetchHistory) Save(filePath string) error {
+	contents, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal fetch history")
+	}
+	return errors.Wrap(fsutil.WriteFileAtomically(filePath, contents), "failed to write fetch history: "+filePath)
+}
+
+// RefreshReason is the reason why the detail of a circle should be fetched.
+type RefreshReason string
+
+const (
+	// RefreshReasonNew means the circle is not stored yet.
+	RefreshReasonNew RefreshReason = "new"
+	// RefreshReasonChanged means the name, space or penname of the circle on the list page differs from the stored one.
+	RefreshReasonChanged RefreshReason = "changed"
+	// RefreshReasonStale means the stored detail is older than max age.
+	RefreshReasonStale RefreshReason = "stale"
+)
+
+// RefreshTarget is a circle whose detail should be fetched.
+type RefreshTarget struct {
+	Circle *tbf.Circle
+	Reason RefreshReason
+}
+
+// FilterRefreshTargets returns circles which are new, differ from the stored circle details,
+// or were fetched more than maxAge ago. Stored circle details are matched by DetailURL.
+// Only the fields which the list page reliably provides are compared (see isCircleChanged).
+// Staleness is not checked if maxAge is zero. Circles which have no fetch history are treated as stale.
+func FilterRefreshTargets(circles []*tbf.Circle, circleDetails []*tbf.CircleDetail, history FetchHistory, maxAge time.Duration, now time.Time) (targets []*RefreshTarget) {
+	storedCircles := map[string]*tbf.Circle{}
+	for _, circleDetail := range circleDetails {
+		storedCircles[circleDetail.DetailURL] = &circleDetail.Circle
+	}
+
+	for _, circle := range circles {
+		stored, ok := storedCircles[circle.DetailURL]
+		switch {
+		case !ok:
+			targets = append(targets, &RefreshTarget{Circle: circle, Reason: RefreshReasonNew})
+		case isCircleChanged(stored, circle):
+			targets = append(targets, &RefreshTarget{Circle: circle, Reason: RefreshReasonChanged})
+		case maxAge > 0 && now.Sub(history[circle.DetailURL]) > maxAge:
+			targets = append(targets, &RefreshTarget{Circle: circle, Reason: RefreshReasonStale})
+		}
+	}
+	return
+}
+
+// isCircleChanged returns true if the name, space or penname of circle on the list page differs from stored.
+// Genre is not compared because the genre on the list page can differ from the one on the detail page
+// (and older tbf scraped it wrongly), so that comparing it would refresh every circle.
+func isCircleChanged(stored, circle *tbf.Circle) bool {
+	return stored.Name != circle.Name || stored.Space != circle.Space || stored.Penname != circle.Penname
+}
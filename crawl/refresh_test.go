@@ -0,0 +1,135 @@
+package crawl_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mpppk/tbf/crawl"
+	"github.com/mpppk/tbf/tbf"
+)
+
+func newCircle(id, space, name string) *tbf.Circle {
+	return &tbf.Circle{
+		DetailURL: "https://techbookfest.org/event/tbf05/circle/" + id,
+		Space:     space,
+		Name:      name,
+		Penname:   "penname",
+		Genre:     "ソフトウェア全般",
+	}
+}
+
+func TestFilterRefreshTargets(t *testing.T) {
+	now := time.Date(2018, 10, 8, 11, 0, 0, 0, time.UTC)
+	stored := []*tbf.CircleDetail{
+		{Circle: *newCircle("1", "あ01", "name1")},
+		{Circle: *newCircle("2", "あ02", "name2")},
+		{Circle: *newCircle("3", "あ03", "name3")},
+	}
+	history := crawl.FetchHistory{
+		stored[0].DetailURL: now.Add(-time.Hour),
+		stored[1].DetailURL: now.Add(-time.Hour),
+		stored[2].DetailURL: now.Add(-48 * time.Hour),
+	}
+	circles := []*tbf.Circle{
+		newCircle("1", "あ01", "name1"),
+		newCircle("2", "あ02", "renamed"),
+		newCircle("3", "あ03", "name3"),
+		newCircle("4", "あ04", "name4"),
+	}
+
+	cases := []struct {
+		maxAge   time.Duration
+		expected map[string]crawl.RefreshReason
+	}{
+		{
+			maxAge: 0,
+			expected: map[string]crawl.RefreshReason{
+				"name4":   crawl.RefreshReasonNew,
+				"renamed": crawl.RefreshReasonChanged,
+			},
+		},
+		{
+			maxAge: 24 * time.Hour,
+			expected: map[string]crawl.RefreshReason{
+				"name4":   crawl.RefreshReasonNew,
+				"renamed": crawl.RefreshReasonChanged,
+				"name3":   crawl.RefreshReasonStale,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		targets := crawl.FilterRefreshTargets(circles, stored, history, c.maxAge, now)
+		actual := map[string]crawl.RefreshReason{}
+		for _, target := range targets {
+			actual[target.Circle.Name] = target.Reason
+		}
+		if len(actual) != len(c.expected) {
+			t.Errorf("FilterRefreshTargets is expected to return %v when max age is %s, but actually return %v",
+				c.expected, c.maxAge, actual)
+			continue
+		}
+		for name, reason := range c.expected {
+			if actual[name] != reason {
+				t.Errorf("%s is expected to be refreshed because %s when max age is %s, but actually %q",
+					name, reason, c.maxAge, actual[name])
+			}
+		}
+	}
+}
+
+func TestFilterRefreshTargets_listAndDetail(t *testing.T) {
+	now := time.Date(2018, 10, 8, 11, 0, 0, 0, time.UTC)
+	const detailURL = "https://techbookfest.org/event/tbf05/circle/28360002"
+	stored := []*tbf.CircleDetail{
+		{
+			Circle: tbf.Circle{
+				DetailURL: detailURL,
+				Space:     "あ02",
+				Name:      "いしだけ（イシダケ）",
+				Penname:   "t_ishida,コンドウアヤ",
+				Genre:     "ソフトウェア全般",
+			},
+			ImageURL:        "https://lh3.googleusercontent.com/DT5P_6OcobmPWDzVnu1loCAt_DDrcmQ8P2Y5hE3RWoRb6Fx-4dcuA7U3oPP3yQyAXr3FzH-6Jc8_iI5Z_1Pp",
+			WebURL:          "http://www.dezapatan.com",
+			GenreFreeFormat: "体系的なプログラミング制作を目指してPHPで緩く解説しています",
+		},
+	}
+	history := crawl.FetchHistory{detailURL: now.Add(-time.Hour)}
+
+	cases := []struct {
+		circle   *tbf.Circle
+		expected []crawl.RefreshReason
+	}{
+		{
+			// genre on the list page was scraped from the wrong element by older tbf
+			circle: &tbf.Circle{DetailURL: detailURL, Space: "あ02", Name: "いしだけ（イシダケ）", Penname: "t_ishida,コンドウアヤ",
+				Genre: "体系的なプログラミング制作を目指してPHPで緩く解説しています"},
+		},
+		{
+			circle: &tbf.Circle{DetailURL: detailURL, Space: "あ02", Name: "いしだけ（イシダケ）", Penname: "t_ishida,コンドウアヤ"},
+		},
+		{
+			circle: &tbf.Circle{DetailURL: detailURL, Space: "あ03", Name: "いしだけ（イシダケ）", Penname: "t_ishida,コンドウアヤ",
+				Genre: "ソフトウェア全般"},
+			expected: []crawl.RefreshReason{crawl.RefreshReasonChanged},
+		},
+		{
+			circle: &tbf.Circle{DetailURL: detailURL, Space: "あ02", Name: "いしだけ（イシダケ）", Penname: "t_ishida",
+				Genre: "ソフトウェア全般"},
+			expected: []crawl.RefreshReason{crawl.RefreshReasonChanged},
+		},
+	}
+
+	for _, c := range cases {
+		targets := crawl.FilterRefreshTargets([]*tbf.Circle{c.circle}, stored, history, 24*time.Hour, now)
+		var actual []crawl.RefreshReason
+		for _, target := range targets {
+			actual = append(actual, target.Reason)
+		}
+		if len(actual) != len(c.expected) || (len(actual) > 0 && actual[0] != c.expected[0]) {
+			t.Errorf("FilterRefreshTargets is expected to return %v when %#v is on the list page, but actually %v",
+				c.expected, c.circle, actual)
+		}
+	}
+}
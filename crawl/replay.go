@@ -0,0 +1,229 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/mpppk/tbf/tbf"
+	"github.com/pkg/errors"
+)
+
+// ReplayCrawler crawls the pages of a recorded crawl session through a local FixtureServer with a crawler backend,
+// so that selectors can be developed and regression tested without the real site.
+// URLs of the recorded site are rewritten to the server and back, so that the results are comparable with the recorded ones.
+type ReplayCrawler struct {
+	Crawler
+	Session *Session
+	Server  *FixtureServer
+
+	origin     *url.URL
+	serverURL  *url.URL
+	m          sync.Mutex
+	mismatches []string
+}
+
+// NewReplayCrawler starts a FixtureServer for the crawl session recorded in dir,
+// and returns a new crawler which crawls the server with backend.
+// Shutdown shuts down the backend and closes the server.
+func NewReplayCrawler(ctx context.Context, backend, dir string, selectors *Selectors) (*ReplayCrawler, error) {
+	session, err := LoadSession(dir)
+	if err != nil {
+		return nil, err
+	}
+	origin, err := url.Parse(session.CirclesURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid circle list URL in crawl session: "+session.CirclesURL)
+	}
+
+	server := NewFixtureServer(dir)
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		server.Close()
+		return nil, errors.Wrap(err, "invalid URL of fixture server: "+server.URL)
+	}
+
+	var crawler Crawler
+	if backend == BackendHTTP {
+		client, err := newFixtureHTTPClient()
+		if err != nil {
+			server.Close()
+			return nil, err
+		}
+		crawler = NewHTTPCrawler(server.URL, selectors, client)
+	} else {
+		crawler, err = NewCrawler(ctx, backend, server.URL, selectors)
+		if err != nil {
+			server.Close()
+			return nil, err
+		}
+	}
+
+	return &ReplayCrawler{
+		Crawler:   crawler,
+		Session:   session,
+		Server:    server,
+		origin:    origin,
+		serverURL: serverURL,
+	}, nil
+}
+
+// FetchCircles fetches the circles on the recorded circle list page of circlesURL.
+func (r *ReplayCrawler) FetchCircles(ctx context.Context, circlesURL string) ([]*tbf.Circle, error) {
+	circles, err := r.Crawler.FetchCircles(ctx, r.toServer(circlesURL))
+	for _, circle := range circles {
+		circle.DetailURL = r.toOrigin(circle.DetailURL)
+	}
+	r.checkCircles(r.resolve(circlesURL), circles, err)
+	return circles, err
+}
+
+// FetchCircleDetail fetches the detail and the books of circle from its recorded circle detail page.
+func (r *ReplayCrawler) FetchCircleDetail(ctx context.Context, circle *tbf.Circle) (*CircleDetailPage, error) {
+	return r.fetchCircleDetail(ctx, r.Crawler.FetchCircleDetail, circle)
+}
+
+// RunWorkers runs work concurrently with n workers of the backend, which fetch the recorded pages.
+func (r *ReplayCrawler) RunWorkers(ctx context.Context, n int, work func(ctx context.Context, w *Worker) error) error {
+	return r.Crawler.RunWorkers(ctx, n, func(ctx context.Context, w *Worker) error {
+		return work(ctx, &Worker{
+			ID: w.ID,
+			fetch: func(ctx context.Context, circle *tbf.Circle) (*CircleDetailPage, error) {
+				return r.fetchCircleDetail(ctx, w.fetch, circle)
+			},
+		})
+	})
+}
+
+// Shutdown shuts down the backend and closes the server.
+func (r *ReplayCrawler) Shutdown(ctx context.Context) error {
+	defer r.Server.Close()
+	return r.Crawler.Shutdown(ctx)
+}
+
+// Mismatches returns the descriptions of the replayed results which differ from the recorded ones.
+func (r *ReplayCrawler) Mismatches() []string {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return append([]string{}, r.mismatches...)
+}
+
+func (r *ReplayCrawler) fetchCircleDetail(ctx context.Context, fetch fetchFunc, circle *tbf.Circle) (*CircleDetailPage, error) {
+	serverCircle := *circle
+	serverCircle.DetailURL = r.toServer(circle.DetailURL)
+
+	page, err := fetch(ctx, &serverCircle)
+	if page != nil && page.CircleDetail != nil {
+		page.CircleDetail.DetailURL = r.toOrigin(page.CircleDetail.DetailURL)
+		page.CircleDetail.ImageURL = r.toOrigin(page.CircleDetail.ImageURL)
+		page.CircleDetail.WebURL = r.toOrigin(page.CircleDetail.WebURL)
+	}
+	if page != nil {
+		for _, book := range page.Books {
+			book.CircleDetailURL = r.toOrigin(book.CircleDetailURL)
+			for i, imageURL := range book.ImageURLs {
+				book.ImageURLs[i] = r.toOrigin(imageURL)
+			}
+		}
+	}
+	if ctx.Err() == nil {
+		r.checkCircleDetail(r.resolve(circle.DetailURL), page, err)
+	}
+	return page, err
+}
+
+func (r *ReplayCrawler) checkCircles(circlesURL string, circles []*tbf.Circle, err error) {
+	page := r.Session.Page(circlesURL)
+	switch {
+	case page == nil:
+		r.addMismatch("%s is not recorded", circlesURL)
+	case err != nil && page.Error == "":
+		r.addMismatch("failed to replay %s, but it succeeded in the recording: %v", circlesURL, err)
+	case err == nil && page.Error != "":
+		r.addMismatch("%s is replayed, but it failed in the recording: %s", circlesURL, page.Error)
+	case err == nil && len(circles) != len(page.Circles):
+		r.addMismatch("%d circles are found on %s, but %d circles are recorded", len(circles), circlesURL, len(page.Circles))
+	case err == nil:
+		for i, circle := range circles {
+			if *circle != *page.Circles[i] {
+				r.addMismatch("circle %d on %s differs from the recording:\n  recorded: %+v\n  replayed: %+v",
+					i, circlesURL, *page.Circles[i], *circle)
+			}
+		}
+	}
+}
+
+func (r *ReplayCrawler) checkCircleDetail(detailURL string, replayed *CircleDetailPage, err error) {
+	page := r.Session.Page(detailURL)
+	switch {
+	case page == nil:
+		r.addMismatch("%s is not recorded", detailURL)
+	case err != nil && page.Error == "":
+		r.addMismatch("failed to replay %s, but it succeeded in the recording: %v", detailURL, err)
+	case err == nil && page.Error != "":
+		r.addMismatch("%s is replayed, but it failed in the recording: %s", detailURL, page.Error)
+	case err == nil && !reflect.DeepEqual(replayed.CircleDetail, page.CircleDetail):
+		r.addMismatch("circle detail of %s differs from the recording:\n  recorded: %+v\n  replayed: %+v",
+			detailURL, page.CircleDetail, replayed.CircleDetail)
+	case err == nil && !booksEqual(replayed.Books, page.Books):
+		r.addMismatch("books of %s differ from the recording:\n  recorded: %s\n  replayed: %s",
+			detailURL, formatBooks(page.Books), formatBooks(replayed.Books))
+	}
+}
+
+// booksEqual returns true if a and b have the same books.
+// Empty books are equal to nil because empty books are not kept in the session file.
+func booksEqual(a, b []*tbf.Book) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func formatBooks(books []*tbf.Book) string {
+	var s []string
+	for _, book := range books {
+		s = append(s, fmt.Sprintf("%+v", *book))
+	}
+	return "[" + strings.Join(s, ", ") + "]"
+}
+
+func (r *ReplayCrawler) addMismatch(format string, a ...interface{}) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.mismatches = append(r.mismatches, fmt.Sprintf(format, a...))
+}
+
+// resolve resolves u against the recorded circle list URL.
+// u is returned as it is if it can not be resolved, so that the backend reports the error.
+func (r *ReplayCrawler) resolve(u string) string {
+	resolved, err := tbf.ResolveURL(r.Session.CirclesURL, u)
+	if err != nil {
+		return u
+	}
+	return resolved
+}
+
+// toServer rewrites u on the recorded site to the URL on the server.
+func (r *ReplayCrawler) toServer(u string) string {
+	return rewriteHost(r.resolve(u), r.origin, r.serverURL)
+}
+
+// toOrigin rewrites u on the server to the URL on the recorded site.
+func (r *ReplayCrawler) toOrigin(u string) string {
+	return rewriteHost(u, r.serverURL, r.origin)
+}
+
+// rewriteHost replaces the scheme and the host of u with the ones of to, if u is on the host of from.
+func rewriteHost(u string, from, to *url.URL) string {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host != from.Host {
+		return u
+	}
+	parsed.Scheme = to.Scheme
+	parsed.Host = to.Host
+	return parsed.String()
+}
@@ -0,0 +1,287 @@
+package crawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// CurrentSelectorsVersion is the version of the selector profile format which is supported by this version of tbf.
+// Version 1 reads the circle detail table by row positions, and version 2 reads it by row labels.
+const CurrentSelectorsVersion = 2
+
+// Selectors is a profile of CSS selectors which are used to extract circle information from techbookfest.org.
+// Each site version has its own profile, so that a redesign of the site can be followed without a new release.
+type Selectors struct {
+	Version int `yaml:"version"`
+	// Name is the name of the profile like "tbf-2018".
+	Name         string                `yaml:"name"`
+	CircleList   CircleListSelectors   `yaml:"circle_list"`
+	CircleDetail CircleDetailSelectors `yaml:"circle_detail"`
+}
+
+// CircleListSelectors are selectors for the circle list page.
+// Selectors other than Item are relative to Item.
+type CircleListSelectors struct {
+	// Item matches each circle on the circle list page.
+	Item string `yaml:"item"`
+	// DetailURL matches the element which has the link to the circle detail page as href.
+	DetailURL string `yaml:"detail_url"`
+	Space     string `yaml:"space"`
+	Name      string `yaml:"name"`
+	Penname   string `yaml:"penname"`
+	Genre     string `yaml:"genre"`
+}
+
+// CircleDetailSelectors are selectors for the circle detail page.
+// Selectors other than Ready and Card are relative to Card.
+type CircleDetailSelectors struct {
+	// Ready matches the element which becomes visible when the circle detail is loaded.
+	Ready string `yaml:"ready"`
+	// Card matches the element which contains the circle detail.
+	Card string `yaml:"card"`
+	// Image matches the element which has the URL of the circle cut as src.
+	Image string `yaml:"image"`
+	Name  string `yaml:"name"`
+
+	// Space, Penname, WebURL, Genre and GenreFreeFormat are used only in version 1.
+	Space           string `yaml:"space,omitempty"`
+	Penname         string `yaml:"penname,omitempty"`
+	WebURL          string `yaml:"web_url,omitempty"`
+	Genre           string `yaml:"genre,omitempty"`
+	GenreFreeFormat string `yaml:"genre_free_format,omitempty"`
+
+	// Table is used instead of them since version 2.
+	Table *DetailTableSelectors `yaml:"table,omitempty"`
+
+	// Books are selectors for the books of the circle. Books are not crawled if it is nil.
+	Books *BookSelectors `yaml:"books,omitempty"`
+}
+
+// DetailTableSelectors are selectors to read the circle detail table by row labels.
+// Row is relative to the card, and Label and Value are relative to each row.
+type DetailTableSelectors struct {
+	Row    string            `yaml:"row"`
+	Label  string            `yaml:"label"`
+	Value  string            `yaml:"value"`
+	Labels DetailTableLabels `yaml:"labels"`
+	// Ignore are labels which are known but not read, like the label of the circle name.
+	Ignore []string `yaml:"ignore,omitempty"`
+}
+
+// DetailTableLabels are the candidate labels of the rows for each field.
+type DetailTableLabels struct {
+	Space           []string `yaml:"space"`
+	Penname         []string `yaml:"penname"`
+	WebURL          []string `yaml:"web_url"`
+	Genre           []string `yaml:"genre"`
+	GenreFreeFormat []string `yaml:"genre_free_format"`
+}
+
+// BookSelectors are selectors to read the books (頒布物) on the circle detail page.
+// Item is relative to the card, and the others are relative to each item.
+// Selectors other than Item and Title are optional, and the fields are left empty if they are not found.
+type BookSelectors struct {
+	// Item matches each book on the circle detail page.
+	Item   string `yaml:"item"`
+	Title  string `yaml:"title"`
+	Price  string `yaml:"price,omitempty"`
+	Pages  string `yaml:"pages,omitempty"`
+	Format string `yaml:"format,omitempty"`
+	// Status matches the element whose text tells whether the book is new or not, like "新刊" or "既刊".
+	Status      string `yaml:"status,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	// Image matches the elements which have the URLs of the book images as src.
+	Image string `yaml:"image,omitempty"`
+	// NewLabels are the texts of Status which mean the book is new. DefaultNewBookLabels are used if it is empty.
+	NewLabels []string `yaml:"new_labels,omitempty"`
+}
+
+// DefaultNewBookLabels are the texts of BookSelectors.Status which mean the book is new.
+var DefaultNewBookLabels = []string{"新刊"}
+
+func (s *CircleListSelectors) fields() map[string]string {
+	return map[string]string{
+		"item":       s.Item,
+		"detail_url": s.DetailURL,
+		"space":      s.Space,
+		"name":       s.Name,
+		"penname":    s.Penname,
+		"genre":      s.Genre,
+	}
+}
+
+func (s *CircleDetailSelectors) fields() map[string]string {
+	return map[string]string{
+		"ready": s.Ready,
+		"card":  s.Card,
+		"image": s.Image,
+		"name":  s.Name,
+	}
+}
+
+func (s *CircleDetailSelectors) positionalFields() map[string]string {
+	return map[string]string{
+		"space":             s.Space,
+		"penname":           s.Penname,
+		"web_url":           s.WebURL,
+		"genre":             s.Genre,
+		"genre_free_format": s.GenreFreeFormat,
+	}
+}
+
+func (t *DetailTableSelectors) fields() map[string]string {
+	return map[string]string{
+		"row":   t.Row,
+		"label": t.Label,
+		"value": t.Value,
+	}
+}
+
+func (b *BookSelectors) fields() map[string]string {
+	return map[string]string{
+		"item":  b.Item,
+		"title": b.Title,
+	}
+}
+
+func (b *BookSelectors) optionalFields() map[string]string {
+	return map[string]string{
+		"price":       b.Price,
+		"pages":       b.Pages,
+		"format":      b.Format,
+		"status":      b.Status,
+		"description": b.Description,
+		"image":       b.Image,
+	}
+}
+
+func (l *DetailTableLabels) fields() map[string][]string {
+	return map[string][]string{
+		"space":             l.Space,
+		"penname":           l.Penname,
+		"web_url":           l.WebURL,
+		"genre":             l.Genre,
+		"genre_free_format": l.GenreFreeFormat,
+	}
+}
+
+// Validate returns error if the profile has unsupported version, or lacks or has malformed selectors.
+func (s *Selectors) Validate() error {
+	if s.Version < 1 || s.Version > CurrentSelectorsVersion {
+		return fmt.Errorf("selector profile version %d is not supported (supported version is up to %d)",
+			s.Version, CurrentSelectorsVersion)
+	}
+	if err := validateSelectorFields("circle_list", s.CircleList.fields()); err != nil {
+		return err
+	}
+	if err := validateSelectorFields("circle_detail", s.CircleDetail.fields()); err != nil {
+		return err
+	}
+	if books := s.CircleDetail.Books; books != nil {
+		if err := validateSelectorFields("circle_detail.books", books.fields()); err != nil {
+			return err
+		}
+		if err := validateOptionalSelectorFields("circle_detail.books", books.optionalFields()); err != nil {
+			return err
+		}
+	}
+
+	if s.Version == 1 {
+		if s.CircleDetail.Table != nil {
+			return errors.New("circle_detail.table can not be used in selector profile version 1")
+		}
+		return validateSelectorFields("circle_detail", s.CircleDetail.positionalFields())
+	}
+
+	positionalFields := s.CircleDetail.positionalFields()
+	for _, key := range sortedKeys(positionalFields) {
+		if positionalFields[key] != "" {
+			return fmt.Errorf("circle_detail.%s can not be used since selector profile version 2. use circle_detail.table instead", key)
+		}
+	}
+	table := s.CircleDetail.Table
+	if table == nil {
+		return errors.New("circle_detail.table is empty")
+	}
+	if err := validateSelectorFields("circle_detail.table", table.fields()); err != nil {
+		return err
+	}
+	labels := table.Labels.fields()
+	for _, key := range sortedKeys(positionalFields) {
+		if len(labels[key]) == 0 {
+			return fmt.Errorf("circle_detail.table.labels.%s is empty", key)
+		}
+	}
+	return nil
+}
+
+func sortedKeys(fields map[string]string) (keys []string) {
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
+
+func validateSelectorFields(prefix string, fields map[string]string) error {
+	for _, key := range sortedKeys(fields) {
+		sel := fields[key]
+		if strings.TrimSpace(sel) == "" {
+			return fmt.Errorf("selector %s.%s is empty", prefix, key)
+		}
+		if _, err := cascadia.Compile(sel); err != nil {
+			return errors.Wrapf(err, "selector %s.%s is invalid: %s", prefix, key, sel)
+		}
+	}
+	return nil
+}
+
+// validateOptionalSelectorFields returns error if fields have malformed selectors. Empty selectors are allowed.
+func validateOptionalSelectorFields(prefix string, fields map[string]string) error {
+	nonEmptyFields := map[string]string{}
+	for key, sel := range fields {
+		if strings.TrimSpace(sel) != "" {
+			nonEmptyFields[key] = sel
+		}
+	}
+	return validateSelectorFields(prefix, nonEmptyFields)
+}
+
+// ParseSelectors parses a selector profile in YAML format.
+func ParseSelectors(contents []byte) (*Selectors, error) {
+	selectors := &Selectors{}
+	if err := yaml.UnmarshalStrict(contents, selectors); err != nil {
+		return nil, errors.Wrap(err, "failed to parse selector profile")
+	}
+	if err := selectors.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid selector profile")
+	}
+	return selectors, nil
+}
+
+// LoadSelectorsFile reads the selector profile file on filePath.
+func LoadSelectorsFile(filePath string) (*Selectors, error) {
+	contents, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read selector profile file: "+filePath)
+	}
+	selectors, err := ParseSelectors(contents)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load selector profile file: "+filePath)
+	}
+	return selectors, nil
+}
+
+// LoadSelectors returns the built-in selector profile which has name, or reads the profile file if name is not built-in.
+func LoadSelectors(name string) (*Selectors, error) {
+	if selectors, ok := BuiltinSelectors(name); ok {
+		return selectors, nil
+	}
+	return LoadSelectorsFile(name)
+}
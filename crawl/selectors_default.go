@@ -0,0 +1,70 @@
+package crawl
+
+import (
+	"sort"
+)
+
+// DefaultSelectorsName is the name of the built-in selector profile which is used if the event does not specify one.
+const DefaultSelectorsName = "tbf-2018"
+
+// builtinSelectorsYAML are the built-in selector profiles keyed by name.
+var builtinSelectorsYAML = map[string]string{
+	"tbf-2018": `
+version: 2
+name: tbf-2018
+circle_list:
+  item: li.circle-list-item
+  detail_url: a.circle-list-item-link
+  space: span.circle-space-label
+  name: span.circle-name
+  penname: p.circle-list-item-penname
+  genre: p.circle-list-item-genre
+circle_detail:
+  ready: mat-card-content.mat-card-content
+  card: mat-card.circle-detail-card
+  image: div.circle-detail-image>img
+  name: tbody span.circle-name
+  table:
+    row: tbody>tr
+    label: td:nth-of-type(1)
+    value: td:nth-of-type(2)
+    labels:
+      space: [スペース, 配置場所]
+      penname: [ペンネーム]
+      web_url: [Webサイト, ウェブサイト]
+      genre: [ジャンル]
+      genre_free_format: [ジャンル自由記入, 頒布物]
+    ignore: [サークル名]
+  books:
+    item: div.circle-book
+    title: .circle-book-title
+    price: .circle-book-price
+    pages: .circle-book-pages
+    format: .circle-book-format
+    status: .circle-book-status
+    description: .circle-book-description
+    image: img.circle-book-image
+`,
+}
+
+// BuiltinSelectors returns a new copy of the built-in selector profile which has name.
+func BuiltinSelectors(name string) (*Selectors, bool) {
+	contents, ok := builtinSelectorsYAML[name]
+	if !ok {
+		return nil, false
+	}
+	selectors, err := ParseSelectors([]byte(contents))
+	if err != nil {
+		panic(err)
+	}
+	return selectors, true
+}
+
+// BuiltinSelectorsNames returns the names of the built-in selector profiles in alphabetical order.
+func BuiltinSelectorsNames() (names []string) {
+	for name := range builtinSelectorsYAML {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
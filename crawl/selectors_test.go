@@ -0,0 +1,172 @@
+package crawl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mpppk/tbf/crawl"
+)
+
+func TestBuiltinSelectors(t *testing.T) {
+	for _, name := range crawl.BuiltinSelectorsNames() {
+		selectors, ok := crawl.BuiltinSelectors(name)
+		if !ok {
+			t.Errorf("built-in selector profile %s is not found", name)
+			continue
+		}
+		if selectors.Name != name {
+			t.Errorf("built-in selector profile %s has different name %s", name, selectors.Name)
+		}
+	}
+
+	if _, ok := crawl.BuiltinSelectors(crawl.DefaultSelectorsName); !ok {
+		t.Errorf("default selector profile %s is not built-in", crawl.DefaultSelectorsName)
+	}
+}
+
+func TestParseSelectors(t *testing.T) {
+	const validCircleDetail = `
+circle_detail:
+  ready: div.ready
+  card: div.card
+  image: img
+  name: .name
+  space: .space
+  penname: .penname
+  web_url: .web a
+  genre: .genre
+  genre_free_format: .description
+`
+	cases := []struct {
+		yaml        string
+		willBeError bool
+	}{
+		{
+			yaml: `
+version: 1
+circle_list:
+  item: li.circle
+  detail_url: a
+  space: .space
+  name: .name
+  penname: .penname
+  genre: .genre
+` + validCircleDetail,
+		},
+		{
+			yaml: `
+version: 3
+circle_list:
+  item: li.circle
+  detail_url: a
+  space: .space
+  name: .name
+  penname: .penname
+  genre: .genre
+` + validCircleDetail,
+			willBeError: true,
+		},
+		{
+			yaml: `
+version: 1
+circle_list:
+  item: li.circle
+  detail_url: a
+  space: .space
+  name: .name
+  penname: .penname
+` + validCircleDetail,
+			willBeError: true,
+		},
+		{
+			yaml: `
+version: 1
+circle_list:
+  item: li.circle
+  detail_url: a[href
+  space: .space
+  name: .name
+  penname: .penname
+  genre: .genre
+` + validCircleDetail,
+			willBeError: true,
+		},
+		{
+			yaml: `
+version: 1
+circle_list:
+  item: li.circle
+  detail_url: a
+  space: .space
+  name: .name
+  penname: .penname
+  genre: .genre
+  unknown: .unknown
+` + validCircleDetail,
+			willBeError: true,
+		},
+	}
+
+	const v2CircleList = `
+circle_list:
+  item: li.circle
+  detail_url: a
+  space: .space
+  name: .name
+  penname: .penname
+  genre: .genre
+`
+	const v2CircleDetail = `
+circle_detail:
+  ready: div.ready
+  card: div.card
+  image: img
+  name: .name
+  table:
+    row: tr
+    label: th
+    value: td
+    labels:
+      space: [スペース]
+      penname: [ペンネーム]
+      web_url: [Webサイト]
+      genre: [ジャンル]
+      genre_free_format: [ジャンル自由記入]
+`
+	cases = append(cases, []struct {
+		yaml        string
+		willBeError bool
+	}{
+		{yaml: "version: 2" + v2CircleList + v2CircleDetail},
+		{yaml: "version: 1" + v2CircleList + v2CircleDetail, willBeError: true},
+		{yaml: "version: 2" + v2CircleList + validCircleDetail, willBeError: true},
+		{yaml: "version: 2" + v2CircleList + strings.Replace(v2CircleDetail, "[ジャンル]", "[]", 1), willBeError: true},
+		{yaml: "version: 2" + v2CircleList + strings.Replace(v2CircleDetail, "label: th", "label: th[", 1), willBeError: true},
+	}...)
+
+	const books = `
+  books:
+    item: div.book
+    title: .title
+    price: .price
+`
+	cases = append(cases, []struct {
+		yaml        string
+		willBeError bool
+	}{
+		{yaml: "version: 2" + v2CircleList + v2CircleDetail + books},
+		{yaml: "version: 1" + v2CircleList + validCircleDetail + books},
+		{yaml: "version: 2" + v2CircleList + v2CircleDetail + strings.Replace(books, "title: .title", "title: ''", 1), willBeError: true},
+		{yaml: "version: 2" + v2CircleList + v2CircleDetail + strings.Replace(books, "price: .price", "price: .price[", 1), willBeError: true},
+	}...)
+
+	for _, c := range cases {
+		_, err := crawl.ParseSelectors([]byte(c.yaml))
+		if c.willBeError && err == nil {
+			t.Errorf("ParseSelectors is expected to be error if %q is given", c.yaml)
+		}
+		if !c.willBeError && err != nil {
+			t.Errorf("Unexpected error occurred when %q is given: %s", c.yaml, err)
+		}
+	}
+}
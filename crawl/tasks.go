@@ -2,13 +2,28 @@ package crawl
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/mpppk/chromedp"
 	"github.com/mpppk/tbf/tbf"
 	"github.com/pkg/errors"
 )
 
+const (
+	// circlesLoadPollInterval is the interval to count the loaded circles on the circle list page.
+	circlesLoadPollInterval = 500 * time.Millisecond
+	// circlesLoadStableDuration is the duration for which the number of circles must not change
+	// to regard the circle list as loaded.
+	circlesLoadStableDuration = 3 * time.Second
+	// circlesLoadTimeout is the time limit to load the circle list.
+	circlesLoadTimeout = 3 * time.Minute
+	// documentSelector matches the root element of the page, to fetch the rendered html of the whole page.
+	documentSelector = "html"
+)
+
 type circlesTasksResult struct {
+	// count is the number of circles which are found on the circle list page.
+	count      int
 	detailUrls []string
 	spaces     []string
 	names      []string
@@ -22,6 +37,9 @@ func (c *circlesTasksResult) validate() error {
 	namesLen := len(c.names)
 	penNamesLen := len(c.penNames)
 	genresLen := len(c.genres)
+	if detailUrlsLen != c.count {
+		return fmt.Errorf("%d circles are found, but %d detail URLs are fetched", c.count, detailUrlsLen)
+	}
 	if detailUrlsLen != spacesLen ||
 		detailUrlsLen != namesLen ||
 		detailUrlsLen != penNamesLen ||
@@ -42,18 +60,19 @@ func NewCirclesTasksResult() *circlesTasksResult {
 		genres:     []string{},
 	}
 }
-func circlesFetchingTasks(circlesURL string) (chromedp.Tasks, *circlesTasksResult) {
+func circlesFetchingTasks(circlesURL string, s *CircleListSelectors) (chromedp.Tasks, *circlesTasksResult) {
 	circlesTasksResult := NewCirclesTasksResult()
-	circleListItemSel := "li.circle-list-item"
-	detailUrlsSel := joinSelectors(circleListItemSel, "a.circle-list-item-link")
-	circleSpacesSel := joinSelectors(circleListItemSel, "span.circle-space-label")
-	circleNamesSel := joinSelectors(circleListItemSel, "span.circle-name")
-	penNamesSel := joinSelectors(circleListItemSel, "p.circle-list-item-penname")
-	genresSel := joinSelectors(circleListItemSel, "p.circle-list-item-penname")
+	detailUrlsSel := joinSelectors(s.Item, s.DetailURL)
+	circleSpacesSel := joinSelectors(s.Item, s.Space)
+	circleNamesSel := joinSelectors(s.Item, s.Name)
+	penNamesSel := joinSelectors(s.Item, s.Penname)
+	genresSel := joinSelectors(s.Item, s.Genre)
 
 	return chromedp.Tasks{
 		chromedp.Navigate(circlesURL),
-		chromedp.WaitVisible(`li.circle-list-item`, chromedp.AtLeast(469)), // FIXME
+		chromedp.WaitVisible(s.Item),
+		WaitCountStable(s.Item, &(circlesTasksResult.count),
+			circlesLoadPollInterval, circlesLoadStableDuration, circlesLoadTimeout),
 		AttributeValueAll(detailUrlsSel, "href", &(circlesTasksResult.detailUrls), nil, chromedp.ByQueryAll),
 		Texts(circleSpacesSel, &(circlesTasksResult.spaces), chromedp.ByQueryAll),
 		Texts(circleNamesSel, &(circlesTasksResult.names), chromedp.ByQueryAll),
@@ -62,31 +81,16 @@ func circlesFetchingTasks(circlesURL string) (chromedp.Tasks, *circlesTasksResul
 	}, circlesTasksResult
 }
 
-func circlesDetailFetchingTasks(fullCircleDetailURL string) (chromedp.Tasks, *tbf.CircleDetail) {
-	circleDetail := &tbf.CircleDetail{}
-	circleDetailCardSel := "mat-card.circle-detail-card"
-	circleDetailTableSel := joinSelectors(circleDetailCardSel, "tbody")
-	tableQueryTmpl := "tr:nth-of-type(%d)>td:nth-of-type(2)"
-
-	circleImageSel := joinSelectors(circleDetailCardSel, "div.circle-detail-image>img")
-	circleNameSel := joinSelectors(circleDetailTableSel, "span.circle-name")
-	circleSpaceSel := joinSelectors(circleDetailTableSel, fmt.Sprintf(tableQueryTmpl, 2))
-	circlePennameSel := joinSelectors(circleDetailTableSel, fmt.Sprintf(tableQueryTmpl, 3))
-	circleWebURLSel := joinSelectors(circleDetailTableSel, fmt.Sprintf(tableQueryTmpl, 4), "a")
-	circleGenreSel := joinSelectors(circleDetailTableSel, fmt.Sprintf(tableQueryTmpl, 5))
-	circleGenreFreeFormatSel := joinSelectors(circleDetailTableSel, fmt.Sprintf(tableQueryTmpl, 6))
-
+// circlesDetailFetchingTasks returns tasks which fetch the rendered html of the circle detail page.
+// Circle detail is extracted from the html by ExtractCircleDetail, so that the crawler and `tbf selectors check` behave the same.
+// The whole page is fetched instead of the card, so that the recorded page can be replayed.
+func circlesDetailFetchingTasks(fullCircleDetailURL string, s *CircleDetailSelectors) (chromedp.Tasks, *string) {
+	var pageHTML string
 	return chromedp.Tasks{
 		chromedp.Navigate(fullCircleDetailURL),
-		chromedp.WaitVisible(`mat-card-content.mat-card-content`),
-		chromedp.AttributeValue(circleImageSel, "src", &(circleDetail.ImageURL), nil, chromedp.ByQueryAll),
-		chromedp.Text(circleNameSel, &(circleDetail.Circle.Name), chromedp.ByQueryAll),
-		chromedp.Text(circleSpaceSel, &(circleDetail.Circle.Space), chromedp.ByQueryAll),
-		chromedp.Text(circlePennameSel, &(circleDetail.Circle.Penname), chromedp.ByQueryAll),
-		chromedp.Text(circleWebURLSel, &(circleDetail.WebURL), chromedp.ByQueryAll),
-		chromedp.Text(circleGenreSel, &(circleDetail.Circle.Genre), chromedp.ByQueryAll),
-		chromedp.Text(circleGenreFreeFormatSel, &(circleDetail.GenreFreeFormat), chromedp.ByQueryAll),
-	}, circleDetail
+		chromedp.WaitVisible(s.Ready),
+		chromedp.OuterHTML(documentSelector, &pageHTML, chromedp.ByQuery),
+	}, &pageHTML
 }
 
 func fetchResultToCircles(res *circlesTasksResult) (circles []*tbf.Circle, err error) {
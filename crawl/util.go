@@ -1,6 +1,7 @@
 package crawl
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/mpppk/tbf/tbf"
@@ -18,3 +19,15 @@ func FilterCircles(circles []*tbf.Circle, circleDetailMap map[string]*tbf.Circle
 	}
 	return
 }
+
+// ValidateCircles splits circles into circles which have a valid space and errors for the others.
+func ValidateCircles(circles []*tbf.Circle) (validCircles []*tbf.Circle, errs []error) {
+	for _, c := range circles {
+		if _, err := tbf.ParseSpace(c.Space); err != nil {
+			errs = append(errs, fmt.Errorf("circle %q (%s) has invalid space: %v", c.Name, c.DetailURL, err))
+			continue
+		}
+		validCircles = append(validCircles, c)
+	}
+	return
+}
@@ -0,0 +1,125 @@
+package csv
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mpppk/tbf/fsutil"
+	"github.com/mpppk/tbf/tbf"
+	"github.com/pkg/errors"
+)
+
+// CurrentBooksVersion is the version of the books file format which is written by WriteBooksFile.
+const CurrentBooksVersion = 1
+
+// BooksFile is the file which stores books of the circles in a circle csv.
+// Books are linked to the circles by CircleDetailURL.
+type BooksFile struct {
+	Version int         `json:"version"`
+	Books   []*tbf.Book `json:"books"`
+}
+
+// BooksFilePath returns the path of the books file for the csv on csvFilePath.
+func BooksFilePath(csvFilePath string) string {
+	return strings.TrimSuffix(csvFilePath, filepath.Ext(csvFilePath)) + ".books.json"
+}
+
+// BooksURL returns the URL of the books file of csvURL,
+// or empty string if csvURL is not a URL of csv file.
+func BooksURL(csvURL string) string {
+	u, err := url.Parse(csvURL)
+	if err != nil || path.Ext(u.Path) != ".csv" {
+		return ""
+	}
+	u.Path = strings.TrimSuffix(u.Path, ".csv") + ".books.json"
+	return u.String()
+}
+
+// ParseBooks parses the contents of a books file.
+func ParseBooks(contents []byte) ([]*tbf.Book, error) {
+	booksFile := &BooksFile{}
+	if err := json.Unmarshal(contents, booksFile); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal books")
+	}
+	if booksFile.Version > CurrentBooksVersion {
+		return nil, fmt.Errorf("books file version %d is not supported (supported version is up to %d). please update tbf",
+			booksFile.Version, CurrentBooksVersion)
+	}
+	return booksFile.Books, nil
+}
+
+// ReadBooksFile reads the books file on filePath. No books are returned if the file does not exist.
+func ReadBooksFile(filePath string) ([]*tbf.Book, error) {
+	contents, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read books from "+filePath)
+	}
+	books, err := ParseBooks(contents)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse books from "+filePath)
+	}
+	return books, nil
+}
+
+// WriteBooksFile writes books to filePath atomically. Books are sorted by space before written.
+func WriteBooksFile(filePath string, books []*tbf.Book) error {
+	books = append([]*tbf.Book{}, books...)
+	tbf.SortBooks(books)
+
+	contents, err := json.MarshalIndent(&BooksFile{Version: CurrentBooksVersion, Books: books}, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal books")
+	}
+	contents = append(contents, '\n')
+	return errors.Wrap(fsutil.WriteFileAtomically(filePath, contents), "failed to write books to "+filePath)
+}
+
+// ReplaceCircleBooks returns books whose books of the circle on circleDetailURL are replaced with circleBooks.
+func ReplaceCircleBooks(books []*tbf.Book, circleDetailURL string, circleBooks []*tbf.Book) []*tbf.Book {
+	var newBooks []*tbf.Book
+	for _, book := range books {
+		if book.CircleDetailURL != circleDetailURL {
+			newBooks = append(newBooks, book)
+		}
+	}
+	return append(newBooks, circleBooks...)
+}
+
+// DownloadBooks downloads the books file on booksURL to filePath.
+// It returns false if the books file does not exist on the server, and filePath is left untouched in that case.
+func DownloadBooks(booksURL, filePath string) (bool, error) {
+	res, err := httpClient.Get(booksURL)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to download books from "+booksURL)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("failed to fetch books from %s: %v", booksURL, res.Status)
+	}
+
+	contents, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read books from "+booksURL)
+	}
+	if _, err := ParseBooks(contents); err != nil {
+		return false, errors.Wrap(err, "invalid books file on "+booksURL)
+	}
+	if err := fsutil.WriteFileAtomically(filePath, contents); err != nil {
+		return false, errors.Wrap(err, "failed to write downloaded books to "+filePath)
+	}
+	return true, nil
+}
@@ -0,0 +1,119 @@
+package csv_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/mpppk/tbf/csv"
+	"github.com/mpppk/tbf/tbf"
+)
+
+func TestBooksURL(t *testing.T) {
+	cases := []struct {
+		csvURL   string
+		expected string
+	}{
+		{csvURL: "https://example.com/tbf05/circles.csv", expected: "https://example.com/tbf05/circles.books.json"},
+		{csvURL: "https://example.com/tbf05/circles", expected: ""},
+	}
+
+	for _, c := range cases {
+		if actual := csv.BooksURL(c.csvURL); actual != c.expected {
+			t.Errorf("BooksURL is expected to return %q when %q is given, but actually %q", c.expected, c.csvURL, actual)
+		}
+	}
+}
+
+func TestWriteBooksFile(t *testing.T) {
+	csvFilePath, cleanup := writeTempCSV(t, "")
+	defer cleanup()
+	filePath := csv.BooksFilePath(csvFilePath)
+	if filepath.Base(filePath) != "circles.books.json" {
+		t.Errorf("books file is expected to be circles.books.json, but actually %s", filePath)
+	}
+
+	books, err := csv.ReadBooksFile(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if len(books) != 0 {
+		t.Errorf("no books are expected if books file does not exist, but actually %d", len(books))
+	}
+
+	const url1, url2 = "https://techbookfest.org/event/tbf05/circle/1", "https://techbookfest.org/event/tbf05/circle/2"
+	books = []*tbf.Book{
+		{CircleDetailURL: url2, Space: "あ02", Title: "old", Price: 500},
+		{CircleDetailURL: url1, Space: "あ01", Title: "book1", Price: 1000, IsNew: true},
+	}
+	books = csv.ReplaceCircleBooks(books, url2, []*tbf.Book{{CircleDetailURL: url2, Space: "あ02", Title: "new", Price: 0}})
+	if err := csv.WriteBooksFile(filePath, books); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	books, err = csv.ReadBooksFile(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if len(books) != 2 || books[0].Title != "book1" || books[1].Title != "new" {
+		t.Errorf("books are expected to be replaced and sorted by space, but actually %#v", books)
+	}
+	if !books[0].IsNew || books[0].Price != 1000 {
+		t.Errorf("book fields are expected to be kept, but actually %#v", books[0])
+	}
+}
+
+func TestDownloadBooks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/circles.books.json":
+			fmt.Fprint(w, `{"version":1,"books":[{"CircleDetailURL":"https://techbookfest.org/event/tbf05/circle/1","Title":"book1"}]}`)
+		case "/future.books.json":
+			fmt.Fprint(w, `{"version":999,"books":[]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	csvFilePath, cleanup := writeTempCSV(t, "")
+	defer cleanup()
+	filePath := csv.BooksFilePath(csvFilePath)
+
+	cases := []struct {
+		path          string
+		expectedFound bool
+		willBeError   bool
+	}{
+		{path: "/missing.books.json"},
+		{path: "/future.books.json", willBeError: true},
+		{path: "/circles.books.json", expectedFound: true},
+	}
+
+	for _, c := range cases {
+		found, err := csv.DownloadBooks(server.URL+c.path, filePath)
+		if c.willBeError {
+			if err == nil {
+				t.Errorf("DownloadBooks is expected to be error if %s is given", c.path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error occurred when %s is given: %s", c.path, err)
+			continue
+		}
+		if found != c.expectedFound {
+			t.Errorf("DownloadBooks is expected to return %t when %s is given, but actually %t", c.expectedFound, c.path, found)
+		}
+	}
+
+	books, err := csv.ReadBooksFile(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if len(books) != 1 || books[0].Title != "book1" {
+		t.Errorf("downloaded books are expected to be readable, but actually %#v", books)
+	}
+}
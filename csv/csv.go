@@ -2,89 +2,161 @@ package csv
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/csv"
 	"fmt"
 	"os"
-	"strings"
-
-	"net/http"
 
 	"io"
 
-	"hash/crc32"
-	"io/ioutil"
-
-	"encoding/json"
-
+	"github.com/mpppk/tbf/fsutil"
 	"github.com/mpppk/tbf/tbf"
 	"github.com/pkg/errors"
 )
 
 type CircleCSV struct {
 	filePath string
+	schema   *Schema
 	headers  []string
+	warnings []error
 }
 
-type Meta struct {
-	Checksum uint32 `json:"checksum"`
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// newReader returns csv reader which skips UTF-8 BOM at the head of r.
+func newReader(r io.Reader) (*csv.Reader, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(len(utf8BOM))
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "failed to read head of csv")
+	}
+	if bytes.Equal(head, utf8BOM) {
+		if _, err := br.Discard(len(utf8BOM)); err != nil {
+			return nil, errors.Wrap(err, "failed to skip BOM")
+		}
+	}
+	return csv.NewReader(br), nil
 }
 
+// NewCircleCSV opens circle csv on filePath and validates its header against the known schemas.
+// The file is created if it does not exist.
 func NewCircleCSV(filePath string) (*CircleCSV, error) {
-	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
-	defer file.Close()
+	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE, 0666)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to open circle csv: "+filePath)
 	}
+	defer file.Close()
+
+	reader, err := newReader(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read circle csv: "+filePath)
+	}
+
+	headers, err := reader.Read()
+	if err == io.EOF {
+		return &CircleCSV{filePath: filePath}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read header of circle csv: "+filePath)
+	}
 
-	var headers []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		headers = strings.Split(line, ",") // FIXME
-		break
+	schema, err := DetectSchema(headers)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid header of circle csv: "+filePath)
 	}
 
 	return &CircleCSV{
 		filePath: filePath,
+		schema:   schema,
 		headers:  headers,
 	}, nil
 }
 
+// FilePath returns the path of the csv file.
+func (c *CircleCSV) FilePath() string {
+	return c.filePath
+}
+
+// Schema returns the schema of the csv. It returns nil if the csv has no header yet.
+func (c *CircleCSV) Schema() *Schema {
+	return c.schema
+}
+
 func (c *CircleCSV) getHeaders() ([]string, bool) {
-	if c.headers != nil && len(c.headers) > 0 {
+	if len(c.headers) > 0 {
 		return c.headers, true
 	}
 	return nil, false
 }
 
-func (c *CircleCSV) AppendLine(line []string) error {
+func (c *CircleCSV) writeLine(line []string) error {
 	file, err := os.OpenFile(c.filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
-	defer file.Close()
 	if err != nil {
 		return errors.Wrap(err, "failed to open circle csv: "+c.filePath)
 	}
+	defer file.Close()
+
+	if err := terminateLastLine(file); err != nil {
+		return errors.Wrap(err, "failed to prepare circle csv for appending: "+c.filePath)
+	}
+
 	writer := csv.NewWriter(file)
 	if err := writer.Write(line); err != nil {
 		return errors.Wrap(err, "failed to write to circle csv: "+c.filePath)
 	}
 	writer.Flush()
-	return nil
+	return errors.Wrap(writer.Error(), "failed to flush circle csv: "+c.filePath)
 }
 
-func (c *CircleCSV) AppendCircleDetail(circleDetail *tbf.CircleDetail) error {
+// terminateLastLine appends a newline if the file does not end with it,
+// so that the next record is not joined to the last line.
+func terminateLastLine(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return nil
+	}
+
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	_, err = file.Write([]byte("\n"))
+	return err
+}
+
+// AppendLine appends line to the csv.
+// It returns error if the number of columns of line disagrees with the header of the csv.
+func (c *CircleCSV) AppendLine(line []string) error {
 	headers, ok := c.getHeaders()
 	if !ok {
-		headers = tbf.CircleDetailToHeaders(circleDetail)
-		c.headers = headers // FIXME
-		if err := c.AppendLine(headers); err != nil {
+		return errors.New("failed to append line to circle csv which has no header: " + c.filePath)
+	}
+	if len(line) != len(headers) {
+		return fmt.Errorf("line has %d columns, but header of %s has %d columns %q",
+			len(line), c.filePath, len(headers), headers)
+	}
+	return c.writeLine(line)
+}
+
+func (c *CircleCSV) AppendCircleDetail(circleDetail *tbf.CircleDetail) error {
+	if _, ok := c.getHeaders(); !ok {
+		schema := CurrentSchema()
+		if err := c.writeLine(schema.Columns); err != nil {
 			return errors.Wrap(err, "failed to append headers to "+c.filePath)
 		}
+		c.schema = schema
+		c.headers = schema.Columns
 	}
 
-	line, err := tbf.CircleDetailToLine(headers, circleDetail)
-
+	line, err := tbf.CircleDetailToLine(c.headers, circleDetail)
 	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("failed to append circle detail struct as line to circle csv: %#v", circleDetail))
+		return errors.Wrap(err, fmt.Sprintf("columns of circle detail disagree with header of %s: %#v", c.filePath, circleDetail))
 	}
 	if err := c.AppendLine(line); err != nil {
 		return errors.Wrap(err, fmt.Sprintf("failed to write circle detail struct as line to csv: %#v", circleDetail))
@@ -92,129 +164,226 @@ func (c *CircleCSV) AppendCircleDetail(circleDetail *tbf.CircleDetail) error {
 	return nil
 }
 
-func (c *CircleCSV) ToCircleDetailMap() (m map[string]*tbf.CircleDetail, err error) {
-	m = map[string]*tbf.CircleDetail{}
-	file, err := os.OpenFile(c.filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+// PutCircleDetail replaces the row which has the same DetailURL as circleDetail,
+// or appends circleDetail if no such row exists. It returns true if the row is replaced.
+// The other rows and the column order are kept as is.
+func (c *CircleCSV) PutCircleDetail(circleDetail *tbf.CircleDetail) (bool, error) {
+	if _, ok := c.getHeaders(); !ok {
+		return false, c.AppendCircleDetail(circleDetail)
+	}
+
+	lines, err := c.readLines()
 	if err != nil {
-		return nil, errors.Wrap(err, fmt.Sprintf("failed to open file: %s", c.filePath))
+		return false, err
 	}
-	defer file.Close()
-	reader := csv.NewReader(file)
-	lines, err := reader.ReadAll()
+
+	headers := lines[0]
+	detailURLIndex := -1
+	for i, header := range headers {
+		if header == "DetailURL" {
+			detailURLIndex = i
+		}
+	}
+	if detailURLIndex < 0 {
+		return false, fmt.Errorf("header of %s has no DetailURL column: %q", c.filePath, headers)
+	}
+
+	line, err := tbf.CircleDetailToLine(headers, circleDetail)
 	if err != nil {
-		return nil, errors.Wrap(err, fmt.Sprintf("failed to read circle csv from %s", c.filePath))
+		return false, errors.Wrap(err, fmt.Sprintf("columns of circle detail disagree with header of %s: %#v", c.filePath, circleDetail))
 	}
 
+	for i := 1; i < len(lines); i++ {
+		if lines[i][detailURLIndex] == circleDetail.DetailURL {
+			lines[i] = line
+			if err := c.writeLines(lines); err != nil {
+				return false, errors.Wrap(err, "failed to write updated circle csv")
+			}
+			return true, nil
+		}
+	}
+
+	return false, c.AppendCircleDetail(circleDetail)
+}
+
+// ToCircleDetails returns circle details in the order of the csv rows.
+// Rows which have malformed URLs are returned as they are, and reported by Warnings.
+func (c *CircleCSV) ToCircleDetails() ([]*tbf.CircleDetail, error) {
+	lines, err := c.readLines()
+	if err != nil {
+		return nil, err
+	}
+
+	c.warnings = nil
+
 	if len(lines) < 1 {
-		return m, nil
+		return nil, nil
 	}
 
 	headers := lines[0]
-
-	for _, line := range lines[1:] {
+	var circleDetails []*tbf.CircleDetail
+	for i, line := range lines[1:] {
 		circleDetail, err := tbf.LineToCircleDetail(headers, line)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to convert csv line to CircleDetail struct")
+		if _, ok := err.(*tbf.MalformedURLError); ok {
+			c.warnings = append(c.warnings, errors.Wrap(err, fmt.Sprintf("csv line %d", i+2)))
+		} else if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to convert csv line %d to CircleDetail struct", i+2))
 		}
-		m[circleDetail.Space] = circleDetail
+		circleDetails = append(circleDetails, circleDetail)
 	}
-	return m, nil
+	return circleDetails, nil
 }
 
-func IsExist(filename string) bool {
-	_, err := os.Stat(filename)
-	return err == nil
+// Warnings returns the problems of the rows which are found by the last ToCircleDetails call,
+// like malformed URLs. The rows are still readable.
+func (c *CircleCSV) Warnings() []error {
+	return c.warnings
 }
 
-func DownloadCSVIfChanged(csvURL, csvMetaURL, filePath string) (bool, error) {
-	if !IsExist(filePath) {
-		return downloadCSV(csvURL, filePath)
+// readLines reads all lines of the csv including the header, and validates the header.
+func (c *CircleCSV) readLines() ([][]string, error) {
+	file, err := os.Open(c.filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to open file: %s", c.filePath))
 	}
+	defer file.Close()
 
-	meta, err := readCSVMetaFromHTTP(csvMetaURL)
+	reader, err := newReader(file)
 	if err != nil {
-		return false, errors.Wrap(
-			err, fmt.Sprintf("failed to download csv meta data from %s", csvMetaURL))
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to read circle csv from %s", c.filePath))
 	}
-
-	checksum, err := getFileCheckSum(filePath)
+	lines, err := reader.ReadAll()
 	if err != nil {
-		return false, errors.Wrap(err, "failed to read csv file")
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to read circle csv from %s", c.filePath))
 	}
 
-	if checksum == meta.Checksum {
-		return false, nil
+	if len(lines) > 0 {
+		if _, err := DetectSchema(lines[0]); err != nil {
+			return nil, errors.Wrap(err, "invalid header of circle csv: "+c.filePath)
+		}
 	}
-
-	fmt.Fprintf(
-		os.Stderr,
-		"csv file will be downloaded becase checksums are different between meta(%v) and local file(%v)\n",
-		meta.Checksum,
-		checksum)
-	return downloadCSV(csvURL, filePath)
+	return lines, nil
 }
 
-func readCSVMetaFromHTTP(csvMetaURL string) (*Meta, error) {
-	res, err := http.Get(csvMetaURL)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to get csv from URL: "+csvMetaURL)
+// writeLines replaces the contents of the csv with lines atomically.
+// The first line must be the header.
+func (c *CircleCSV) writeLines(lines [][]string) error {
+	buf := &bytes.Buffer{}
+	writer := csv.NewWriter(buf)
+	if err := writer.WriteAll(lines); err != nil {
+		return errors.Wrap(err, "failed to write lines")
 	}
 
-	if res.StatusCode != 200 {
-		return nil, errors.New(
-			fmt.Sprintf("failed to fetch csv from %s: invalid statuscode: %v", csvMetaURL, res.Status))
+	if err := fsutil.WriteFileAtomically(c.filePath, buf.Bytes()); err != nil {
+		return errors.Wrap(err, "failed to write circle csv to "+c.filePath)
 	}
+	c.headers = lines[0]
+	return nil
+}
 
-	csvMetaJsonBytes, err := ioutil.ReadAll(res.Body)
+func (c *CircleCSV) ToCircleDetailMap() (map[string]*tbf.CircleDetail, error) {
+	circleDetails, err := c.ToCircleDetails()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to read http response")
+		return nil, err
 	}
 
-	meta := &Meta{}
-	if err = json.Unmarshal(csvMetaJsonBytes, meta); err != nil {
-		return nil, errors.Wrap(err,
-			fmt.Sprintf(
-				"failed to unmarshal latest csv json from %s, contents: %s",
-				csvMetaURL,
-				string(csvMetaJsonBytes)))
+	m := map[string]*tbf.CircleDetail{}
+	for _, circleDetail := range circleDetails {
+		m[circleDetail.Space] = circleDetail
 	}
-	return meta, nil
+	return m, nil
 }
 
-func downloadCSV(csvURL, filePath string) (bool, error) {
-	res, err := http.Get(csvURL)
+// Normalize rewrites the csv with the canonical column order of the current schema
+// and sorts rows by space, so that the same circle details always produce the same file.
+func (c *CircleCSV) Normalize() error {
+	circleDetails, err := c.ToCircleDetails()
 	if err != nil {
-		return false, errors.Wrap(err, "failed to download CSV from "+csvURL)
+		return errors.Wrap(err, "failed to read circle csv: "+c.filePath)
 	}
+	tbf.SortCircleDetails(circleDetails, nil)
+	return errors.Wrap(c.writeCircleDetails(circleDetails), "failed to write normalized circle csv")
+}
 
-	if res.StatusCode != 200 {
-		return false, errors.New(
-			fmt.Sprintf("failed to fetch csv from %s: %v", csvURL, res.Status))
+// Upgrade rewrites the csv with the columns of the current schema if the csv has an older schema,
+// so that the fields which are added in the newer schema can be saved. The row order is kept as is.
+// It returns true if the csv is upgraded.
+func (c *CircleCSV) Upgrade() (bool, error) {
+	if c.schema == nil || c.schema.Version >= CurrentSchemaVersion {
+		return false, nil
 	}
 
-	file, err := os.Create(filePath)
+	circleDetails, err := c.ToCircleDetails()
 	if err != nil {
-		return false, errors.Wrap(err, "failed to create csv file to "+filePath)
+		return false, errors.Wrap(err, "failed to read circle csv: "+c.filePath)
 	}
-	defer file.Close()
-
-	_, err = io.Copy(file, res.Body)
-	if err != nil {
-		return false, errors.Wrap(err, "failed to write to downloaded csv to "+filePath)
+	if err := c.writeCircleDetails(circleDetails); err != nil {
+		return false, errors.Wrap(err, "failed to write upgraded circle csv")
 	}
-
 	return true, nil
 }
 
-func getFileCheckSum(filePath string) (uint32, error) {
-	if !IsExist(filePath) {
-		return 0, errors.New("csv file not found: " + filePath)
+// writeCircleDetails replaces the contents of the csv with circleDetails in the canonical column order of the current schema.
+func (c *CircleCSV) writeCircleDetails(circleDetails []*tbf.CircleDetail) error {
+	schema := CurrentSchema()
+	lines := [][]string{schema.Columns}
+	for _, circleDetail := range circleDetails {
+		line, err := tbf.CircleDetailToLine(schema.Columns, circleDetail)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to convert circle detail to line: %#v", circleDetail))
+		}
+		lines = append(lines, line)
 	}
 
-	contents, err := ioutil.ReadFile(filePath)
+	if err := c.writeLines(lines); err != nil {
+		return err
+	}
+	c.schema = schema
+	return nil
+}
+
+// RepairURLs repairs DetailURL and ImageURL columns which were broken by older versions of the crawler,
+// and returns the number of repaired URLs. The column and row order are kept as is.
+func (c *CircleCSV) RepairURLs() (int, error) {
+	lines, err := c.readLines()
 	if err != nil {
-		return 0, errors.Wrap(err, "failed to read csv file")
+		return 0, err
+	}
+	if len(lines) < 1 {
+		return 0, nil
+	}
+
+	var urlColumnIndices []int
+	for i, header := range lines[0] {
+		if header == "DetailURL" || header == "ImageURL" {
+			urlColumnIndices = append(urlColumnIndices, i)
+		}
+	}
+
+	repairedNum := 0
+	for lineNum, line := range lines[1:] {
+		for _, i := range urlColumnIndices {
+			repaired, err := tbf.RepairURL(line[i])
+			if err != nil {
+				return 0, errors.Wrap(err, fmt.Sprintf("failed to repair %s on line %d", lines[0][i], lineNum+2))
+			}
+			if repaired != line[i] {
+				line[i] = repaired
+				repairedNum++
+			}
+		}
 	}
 
-	return crc32.ChecksumIEEE(contents), nil
+	if repairedNum == 0 {
+		return 0, nil
+	}
+	if err := c.writeLines(lines); err != nil {
+		return 0, errors.Wrap(err, "failed to write repaired circle csv")
+	}
+	return repairedNum, nil
+}
+
+func IsExist(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
 }
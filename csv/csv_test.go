@@ -0,0 +1,328 @@
+package csv_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mpppk/tbf/csv"
+	"github.com/mpppk/tbf/tbf"
+)
+
+const v1Header = "DetailURL,Space,Name,Penname,Genre,ImageURL,WebURL,GenreFreeFormat"
+const v2Header = v1Header + ",ImageHash,ImagePath"
+
+func generateCircleDetail(space string) *tbf.CircleDetail {
+	return &tbf.CircleDetail{
+		Circle: tbf.Circle{
+			DetailURL: "https://techbookfest.org/event/tbf05/circle/" + space,
+			Space:     space,
+			Name:      "dummyName",
+			Penname:   "t_ishida,コンドウアヤ",
+			Genre:     "ソフトウェア全般",
+		},
+		GenreFreeFormat: "dummyGenreFreeFormat",
+	}
+}
+
+func writeTempCSV(t *testing.T, contents string) (string, func()) {
+	dir, err := ioutil.TempDir("", "tbf-csv-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	filePath := filepath.Join(dir, "circles.csv")
+	if contents != "" {
+		if err := ioutil.WriteFile(filePath, []byte(contents), 0666); err != nil {
+			t.Fatalf("failed to write temp csv: %s", err)
+		}
+	}
+	return filePath, func() { os.RemoveAll(dir) }
+}
+
+func TestNewCircleCSV(t *testing.T) {
+	cases := []struct {
+		name            string
+		contents        string
+		expectedVersion int
+		willBeError     bool
+	}{
+		{name: "new file", contents: ""},
+		{name: "v1 header", contents: v1Header + "\n", expectedVersion: 1},
+		{name: "v2 header", contents: v2Header + "\n", expectedVersion: 2},
+		{name: "shuffled header", contents: "Penname,Genre,DetailURL,Space,ImageURL,WebURL,GenreFreeFormat,Name\n", expectedVersion: 1},
+		{name: "BOM and CRLF", contents: "\xEF\xBB\xBF" + v1Header + "\r\n", expectedVersion: 1},
+		{name: "quoted header", contents: strings.Replace(v1Header, "Name,", `"Name",`, 1) + "\n", expectedVersion: 1},
+		{name: "partial v2 header", contents: v1Header + ",ImageHash\n", willBeError: true},
+		{name: "missing column", contents: strings.Replace(v1Header, ",GenreFreeFormat", "", 1) + "\n", willBeError: true},
+		{name: "unknown column", contents: v1Header + ",Unknown\n", willBeError: true},
+		{name: "duplicated column", contents: v1Header + ",Space\n", willBeError: true},
+	}
+
+	for _, c := range cases {
+		filePath, cleanup := writeTempCSV(t, c.contents)
+		circleCSV, err := csv.NewCircleCSV(filePath)
+		cleanup()
+
+		if c.willBeError {
+			if err == nil {
+				t.Errorf("%s: NewCircleCSV is expected to be error", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Unexpected error occurred: %s", c.name, err)
+			continue
+		}
+		if c.contents != "" && circleCSV.Schema().Version != c.expectedVersion {
+			t.Errorf("%s: schema version is expected to be %d, but actually %d",
+				c.name, c.expectedVersion, circleCSV.Schema().Version)
+		}
+	}
+}
+
+func TestCircleCSV_AppendCircleDetail(t *testing.T) {
+	cases := []struct {
+		name     string
+		contents string
+	}{
+		{name: "new file", contents: ""},
+		{name: "BOM and CRLF", contents: "\xEF\xBB\xBF" + v1Header + "\r\n"},
+		{name: "no trailing newline", contents: "Penname,Genre,DetailURL,Space,ImageURL,WebURL,GenreFreeFormat,Name"},
+	}
+
+	for _, c := range cases {
+		filePath, cleanup := writeTempCSV(t, c.contents)
+		func() {
+			defer cleanup()
+
+			circleCSV, err := csv.NewCircleCSV(filePath)
+			if err != nil {
+				t.Fatalf("%s: Unexpected error occurred: %s", c.name, err)
+			}
+
+			for _, space := range []string{"あ01", "あ02"} {
+				if err := circleCSV.AppendCircleDetail(generateCircleDetail(space)); err != nil {
+					t.Fatalf("%s: Unexpected error occurred when circle detail is appended: %s", c.name, err)
+				}
+			}
+
+			circleCSV, err = csv.NewCircleCSV(filePath)
+			if err != nil {
+				t.Fatalf("%s: Unexpected error occurred when csv is reopened: %s", c.name, err)
+			}
+			m, err := circleCSV.ToCircleDetailMap()
+			if err != nil {
+				t.Fatalf("%s: Unexpected error occurred when csv is parsed: %s", c.name, err)
+			}
+
+			for _, space := range []string{"あ01", "あ02"} {
+				actual, ok := m[space]
+				if !ok {
+					t.Errorf("%s: circle on %s is not found in %v", c.name, space, m)
+					continue
+				}
+				if *actual != *generateCircleDetail(space) {
+					t.Errorf("%s: circle detail is expected to be %#v, but actually %#v",
+						c.name, generateCircleDetail(space), actual)
+				}
+			}
+		}()
+	}
+}
+
+func TestCircleCSV_AppendLine(t *testing.T) {
+	filePath, cleanup := writeTempCSV(t, v1Header+"\n")
+	defer cleanup()
+
+	circleCSV, err := csv.NewCircleCSV(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	if err := circleCSV.AppendLine([]string{"a", "b"}); err == nil {
+		t.Errorf("AppendLine is expected to be error if line columns disagree with header")
+	}
+}
+
+func TestCurrentSchema(t *testing.T) {
+	if !reflect.DeepEqual(csv.CurrentSchema().Columns, tbf.CircleDetailColumns()) {
+		t.Errorf("columns of current schema %q must be same as columns of CircleDetail %q",
+			csv.CurrentSchema().Columns, tbf.CircleDetailColumns())
+	}
+}
+
+func TestCircleCSV_Normalize(t *testing.T) {
+	contents := "Penname,Genre,DetailURL,Space,ImageURL,WebURL,GenreFreeFormat,Name\r\n" +
+		"p2,g2,http://example.com/2,あ10,http://example.com/img2,web2,free2,n2\r\n" +
+		"\"p1,p3\",g1,http://example.com/1,あ2,,web1,free1,n1\r\n"
+	expected := v2Header + "\n" +
+		"http://example.com/1,あ2,n1,\"p1,p3\",g1,,web1,free1,,\n" +
+		"http://example.com/2,あ10,n2,p2,g2,http://example.com/img2,web2,free2,,\n"
+
+	filePath, cleanup := writeTempCSV(t, contents)
+	defer cleanup()
+
+	circleCSV, err := csv.NewCircleCSV(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if err := circleCSV.Normalize(); err != nil {
+		t.Fatalf("Unexpected error occurred when csv is normalized: %s", err)
+	}
+
+	actual, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("failed to read normalized csv: %s", err)
+	}
+	if string(actual) != expected {
+		t.Errorf("normalized csv is expected to be\n%s\nbut actually\n%s", expected, actual)
+	}
+}
+
+func TestCircleCSV_Upgrade(t *testing.T) {
+	contents := "Penname,Genre,DetailURL,Space,ImageURL,WebURL,GenreFreeFormat,Name\n" +
+		"p2,g2,http://example.com/2,あ10,http://example.com/img2,web2,free2,n2\n" +
+		"p1,g1,http://example.com/1,あ2,,web1,free1,n1\n"
+	expected := v2Header + "\n" +
+		"http://example.com/2,あ10,n2,p2,g2,http://example.com/img2,web2,free2,,\n" +
+		"http://example.com/1,あ2,n1,p1,g1,,web1,free1,,\n"
+
+	filePath, cleanup := writeTempCSV(t, contents)
+	defer cleanup()
+
+	circleCSV, err := csv.NewCircleCSV(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	upgraded, err := circleCSV.Upgrade()
+	if err != nil {
+		t.Fatalf("Unexpected error occurred when csv is upgraded: %s", err)
+	}
+	if !upgraded {
+		t.Errorf("v1 csv is expected to be upgraded")
+	}
+
+	actual, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("failed to read upgraded csv: %s", err)
+	}
+	if string(actual) != expected {
+		t.Errorf("upgraded csv is expected to be\n%s\nbut actually\n%s", expected, actual)
+	}
+
+	circleDetail := generateCircleDetail("あ01")
+	circleDetail.ImageHash = "abc"
+	circleDetail.ImagePath = "ab/abc.png"
+	if _, err := circleCSV.PutCircleDetail(circleDetail); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	circleDetailMap, err := circleCSV.ToCircleDetailMap()
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if stored := circleDetailMap["あ01"]; stored == nil || stored.ImageHash != "abc" || stored.ImagePath != "ab/abc.png" {
+		t.Errorf("image of circle detail is expected to be saved to upgraded csv, but actually %#v", stored)
+	}
+
+	if upgraded, err := circleCSV.Upgrade(); err != nil || upgraded {
+		t.Errorf("csv which has the current schema is expected not to be upgraded, but actually %v, %v", upgraded, err)
+	}
+}
+
+func TestCircleCSV_RepairURLs(t *testing.T) {
+	contents := v1Header + "\n" +
+		"https:/techbookfest.org/event/tbf05/circle/1,あ01,n1,p1,g1,/assets/images/dummy_cut.png,web1,free1\n" +
+		"https://techbookfest.org/event/tbf05/circle/2,あ02,n2,p2,g2,,ec.nikkeibp.co.jp/nsp/,free2\n"
+	expected := v1Header + "\n" +
+		"https://techbookfest.org/event/tbf05/circle/1,あ01,n1,p1,g1,https://techbookfest.org/assets/images/dummy_cut.png,web1,free1\n" +
+		"https://techbookfest.org/event/tbf05/circle/2,あ02,n2,p2,g2,,ec.nikkeibp.co.jp/nsp/,free2\n"
+
+	filePath, cleanup := writeTempCSV(t, contents)
+	defer cleanup()
+
+	circleCSV, err := csv.NewCircleCSV(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	circleDetails, err := circleCSV.ToCircleDetails()
+	if err != nil {
+		t.Fatalf("csv which has malformed URLs is expected to be readable, but error occurred: %s", err)
+	}
+	if len(circleDetails) != 2 {
+		t.Errorf("rows which have malformed URLs are expected to be returned, but actually %d rows", len(circleDetails))
+	}
+	if warnings := circleCSV.Warnings(); len(warnings) != 1 {
+		t.Errorf("1 warning is expected for the row which has malformed URLs, but actually %v", warnings)
+	}
+
+	repairedNum, err := circleCSV.RepairURLs()
+	if err != nil {
+		t.Fatalf("Unexpected error occurred when URLs are repaired: %s", err)
+	}
+	if repairedNum != 2 {
+		t.Errorf("RepairURLs is expected to repair 2 URLs, but actually %d", repairedNum)
+	}
+
+	actual, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("failed to read repaired csv: %s", err)
+	}
+	if string(actual) != expected {
+		t.Errorf("repaired csv is expected to be\n%s\nbut actually\n%s", expected, actual)
+	}
+	if _, err := circleCSV.ToCircleDetails(); err != nil {
+		t.Fatalf("Unexpected error occurred when repaired csv is parsed: %s", err)
+	}
+	if warnings := circleCSV.Warnings(); len(warnings) != 0 {
+		t.Errorf("repaired csv is expected to have no warnings, but actually %v", warnings)
+	}
+}
+
+func TestCircleCSV_PutCircleDetail(t *testing.T) {
+	filePath, cleanup := writeTempCSV(t, "")
+	defer cleanup()
+
+	circleCSV, err := csv.NewCircleCSV(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	for _, space := range []string{"あ02", "あ01"} {
+		replaced, err := circleCSV.PutCircleDetail(generateCircleDetail(space))
+		if err != nil {
+			t.Fatalf("Unexpected error occurred: %s", err)
+		}
+		if replaced {
+			t.Errorf("new circle detail on %s is expected to be appended", space)
+		}
+	}
+
+	updated := generateCircleDetail("あ02")
+	updated.Name = "updated"
+	replaced, err := circleCSV.PutCircleDetail(updated)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if !replaced {
+		t.Errorf("circle detail which has the same DetailURL is expected to be replaced")
+	}
+
+	circleDetails, err := circleCSV.ToCircleDetails()
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if len(circleDetails) != 2 || circleDetails[0].Name != "updated" || circleDetails[1].Space != "あ01" {
+		t.Errorf("row is expected to be updated in place, but actually %#v", circleDetails)
+	}
+
+	headers := strings.Replace(strings.Join(csv.CurrentSchema().Columns, ","), "DetailURL,", "", 1)
+	if err := ioutil.WriteFile(filePath, []byte(headers+"\n"), 0666); err != nil {
+		t.Fatalf("failed to write csv: %s", err)
+	}
+	if _, err := circleCSV.PutCircleDetail(updated); err == nil {
+		t.Errorf("PutCircleDetail is expected to be error if csv has no DetailURL column")
+	}
+}
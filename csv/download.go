@@ -0,0 +1,187 @@
+package csv
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/mpppk/tbf/fsutil"
+	"github.com/pkg/errors"
+)
+
+// HTTPValidators are HTTP cache validators of a downloaded csv.
+// They are sent with the next request so that the server can answer 304 Not Modified if the csv is not changed.
+type HTTPValidators struct {
+	ETag         string
+	LastModified string
+}
+
+func newHTTPValidators(header http.Header) *HTTPValidators {
+	return &HTTPValidators{
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+	}
+}
+
+// IsEmpty returns true if v has no validators.
+func (v *HTTPValidators) IsEmpty() bool {
+	return v == nil || (v.ETag == "" && v.LastModified == "")
+}
+
+// DownloadResult is the result of DownloadCSVIfChanged.
+type DownloadResult struct {
+	// Downloaded is true if the csv is newly downloaded.
+	Downloaded bool
+	// Validators are the validators to be stored for the next download.
+	Validators *HTTPValidators
+}
+
+// MetaURL returns the URL of the checksum meta file of csvURL,
+// or empty string if csvURL is not a URL of csv file.
+func MetaURL(csvURL string) string {
+	u, err := url.Parse(csvURL)
+	if err != nil || path.Ext(u.Path) != ".csv" {
+		return ""
+	}
+	u.Path = strings.TrimSuffix(u.Path, ".csv") + ".json"
+	return u.String()
+}
+
+// DownloadCSVIfChanged downloads the csv on csvURL to filePath if the remote csv is changed.
+// If validators of the previous download are given, the change is detected by a conditional request.
+// Otherwise the digest in the meta file on csvMetaURL is compared with the local file if the meta file exists.
+// The csv is always downloaded if neither of them is available.
+//
+// The downloaded csv is verified against the digest in the meta file if it exists, and then replaces filePath atomically.
+// If the download or the verification fails, the file on filePath is left untouched so that it can be used as a fallback.
+func DownloadCSVIfChanged(csvURL, csvMetaURL, filePath string, validators *HTTPValidators) (*DownloadResult, error) {
+	if !IsExist(filePath) {
+		return downloadCSV(csvURL, csvMetaURL, filePath, nil, nil)
+	}
+
+	if !validators.IsEmpty() {
+		return downloadCSV(csvURL, csvMetaURL, filePath, validators, nil)
+	}
+
+	var meta *Meta
+	if csvMetaURL != "" {
+		m, err := readCSVMetaFromHTTP(csvMetaURL)
+		if err != nil {
+			return nil, errors.Wrap(
+				err, fmt.Sprintf("failed to download csv meta data from %s", csvMetaURL))
+		}
+		meta = m
+
+		if meta != nil {
+			contents, err := ioutil.ReadFile(filePath)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to read csv file")
+			}
+
+			err = meta.Verify(contents)
+			if err == nil {
+				return &DownloadResult{Downloaded: false}, nil
+			}
+
+			fmt.Fprintf(os.Stderr, "csv file will be downloaded because local file is different from meta: %v\n", err)
+		}
+	}
+
+	return downloadCSV(csvURL, csvMetaURL, filePath, nil, meta)
+}
+
+// readCSVMetaFromHTTP returns nil if the meta file does not exist.
+func readCSVMetaFromHTTP(csvMetaURL string) (*Meta, error) {
+	res, err := httpClient.Get(csvMetaURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get csv from URL: "+csvMetaURL)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New(
+			fmt.Sprintf("failed to fetch csv from %s: invalid statuscode: %v", csvMetaURL, res.Status))
+	}
+
+	csvMetaJsonBytes, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read http response")
+	}
+
+	meta, err := ParseMeta(csvMetaJsonBytes)
+	if err != nil {
+		return nil, errors.Wrap(err,
+			fmt.Sprintf(
+				"failed to unmarshal latest csv json from %s, contents: %s",
+				csvMetaURL,
+				string(csvMetaJsonBytes)))
+	}
+	return meta, nil
+}
+
+// downloadCSV downloads the csv on csvURL and replaces filePath with it after verification.
+// meta is fetched from csvMetaURL if it is nil and csvMetaURL is not empty.
+func downloadCSV(csvURL, csvMetaURL, filePath string, validators *HTTPValidators, meta *Meta) (*DownloadResult, error) {
+	req, err := http.NewRequest(http.MethodGet, csvURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request to "+csvURL)
+	}
+	if validators != nil {
+		if validators.ETag != "" {
+			req.Header.Set("If-None-Match", validators.ETag)
+		}
+		if validators.LastModified != "" {
+			req.Header.Set("If-Modified-Since", validators.LastModified)
+		}
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to download CSV from "+csvURL)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified {
+		return &DownloadResult{Downloaded: false, Validators: validators}, nil
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New(
+			fmt.Sprintf("failed to fetch csv from %s: %v", csvURL, res.Status))
+	}
+
+	contents, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read csv from "+csvURL)
+	}
+	if res.ContentLength >= 0 && int64(len(contents)) != res.ContentLength {
+		return nil, fmt.Errorf("csv from %s is truncated: expected %d bytes, but got %d bytes",
+			csvURL, res.ContentLength, len(contents))
+	}
+
+	if meta == nil && csvMetaURL != "" {
+		if meta, err = readCSVMetaFromHTTP(csvMetaURL); err != nil {
+			return nil, errors.Wrap(
+				err, fmt.Sprintf("failed to download csv meta data from %s", csvMetaURL))
+		}
+	}
+	if meta != nil {
+		if err := meta.Verify(contents); err != nil {
+			return nil, errors.Wrap(err, "failed to verify csv from "+csvURL)
+		}
+	}
+
+	if err := fsutil.WriteFileAtomically(filePath, contents); err != nil {
+		return nil, errors.Wrap(err, "failed to write to downloaded csv to "+filePath)
+	}
+
+	return &DownloadResult{Downloaded: true, Validators: newHTTPValidators(res.Header)}, nil
+}
@@ -0,0 +1,161 @@
+package csv_test
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/mpppk/tbf/csv"
+)
+
+const remoteCSV = v1Header + "\n"
+
+// newCSVServer returns a server which serves remoteCSV on /circles.csv with ETag and Last-Modified,
+// and its checksum meta on /circles.json if withMeta is true.
+func newCSVServer(withMeta bool) (*httptest.Server, *int) {
+	const etag = `"v1"`
+	const lastModified = "Mon, 08 Oct 2018 11:00:00 GMT"
+	csvRequestNum := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/circles.csv", func(w http.ResponseWriter, r *http.Request) {
+		csvRequestNum++
+		if r.Header.Get("If-None-Match") == etag || r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		fmt.Fprint(w, remoteCSV)
+	})
+	mux.HandleFunc("/circles.json", func(w http.ResponseWriter, r *http.Request) {
+		if !withMeta {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"checksum":%d}`, crc32.ChecksumIEEE([]byte(remoteCSV)))
+	})
+	return httptest.NewServer(mux), &csvRequestNum
+}
+
+func TestDownloadCSVIfChanged_Verification(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/circles.json" {
+			fmt.Fprint(w, `{"checksum":1}`)
+			return
+		}
+		fmt.Fprint(w, remoteCSV)
+	}))
+	defer server.Close()
+
+	const localCSV = "previous"
+	filePath, cleanup := writeTempCSV(t, localCSV)
+	defer cleanup()
+
+	if _, err := csv.DownloadCSVIfChanged(server.URL+"/circles.csv", server.URL+"/circles.json", filePath, nil); err == nil {
+		t.Errorf("DownloadCSVIfChanged is expected to be error if checksum of downloaded csv is different from meta")
+	}
+
+	contents, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if string(contents) != localCSV {
+		t.Errorf("previous csv is expected to be kept if verification fails, but actually %q", contents)
+	}
+
+	files, err := ioutil.ReadDir(filepath.Dir(filePath))
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("temporary files are expected to be removed, but directory has %d files", len(files))
+	}
+}
+
+func TestMetaURL(t *testing.T) {
+	cases := []struct {
+		url      string
+		expected string
+	}{
+		{
+			url:      "https://raw.githubusercontent.com/mpppk/tbf/master/data/tbf5_circles.csv",
+			expected: "https://raw.githubusercontent.com/mpppk/tbf/master/data/tbf5_circles.json",
+		},
+		{url: "https://example.com/circles.csv?raw=true", expected: "https://example.com/circles.json?raw=true"},
+		{url: "https://example.com/csv/circles", expected: ""},
+	}
+
+	for _, c := range cases {
+		if actual := csv.MetaURL(c.url); actual != c.expected {
+			t.Errorf("MetaURL is expected to return %q when %q is given, but actually return %q", c.expected, c.url, actual)
+		}
+	}
+}
+
+func TestDownloadCSVIfChanged(t *testing.T) {
+	cases := []struct {
+		name               string
+		withMeta           bool
+		localCSV           string
+		validators         *csv.HTTPValidators
+		expectedDownloaded bool
+		expectedRequestNum int
+	}{
+		{name: "no local file", localCSV: "", expectedDownloaded: true, expectedRequestNum: 1},
+		{
+			name:               "not modified by ETag",
+			localCSV:           remoteCSV,
+			validators:         &csv.HTTPValidators{ETag: `"v1"`},
+			expectedDownloaded: false,
+			expectedRequestNum: 1,
+		},
+		{
+			name:               "not modified by Last-Modified",
+			localCSV:           remoteCSV,
+			validators:         &csv.HTTPValidators{LastModified: "Mon, 08 Oct 2018 11:00:00 GMT"},
+			expectedDownloaded: false,
+			expectedRequestNum: 1,
+		},
+		{
+			name:               "modified",
+			localCSV:           "old",
+			validators:         &csv.HTTPValidators{ETag: `"v0"`},
+			expectedDownloaded: true,
+			expectedRequestNum: 1,
+		},
+		{name: "same checksum", withMeta: true, localCSV: remoteCSV, expectedDownloaded: false, expectedRequestNum: 0},
+		{name: "different checksum", withMeta: true, localCSV: "old", expectedDownloaded: true, expectedRequestNum: 1},
+		{name: "no validators and meta", localCSV: remoteCSV, expectedDownloaded: true, expectedRequestNum: 1},
+	}
+
+	for _, c := range cases {
+		server, csvRequestNum := newCSVServer(c.withMeta)
+		filePath, cleanup := writeTempCSV(t, c.localCSV)
+
+		result, err := csv.DownloadCSVIfChanged(server.URL+"/circles.csv", server.URL+"/circles.json", filePath, c.validators)
+		if err != nil {
+			t.Errorf("%s: Unexpected error occurred: %s", c.name, err)
+		} else {
+			if result.Downloaded != c.expectedDownloaded {
+				t.Errorf("%s: Downloaded is expected to be %v, but actually %v", c.name, c.expectedDownloaded, result.Downloaded)
+			}
+			if *csvRequestNum != c.expectedRequestNum {
+				t.Errorf("%s: csv is expected to be requested %d times, but actually %d times",
+					c.name, c.expectedRequestNum, *csvRequestNum)
+			}
+			if result.Downloaded && (result.Validators == nil || result.Validators.ETag != `"v1"`) {
+				t.Errorf("%s: ETag of downloaded csv is expected to be returned, but actually %#v", c.name, result.Validators)
+			}
+			if contents, err := ioutil.ReadFile(filePath); err != nil || string(contents) != remoteCSV {
+				t.Errorf("%s: local csv is expected to be same as remote csv, but actually %q", c.name, contents)
+			}
+		}
+
+		cleanup()
+		server.Close()
+	}
+}
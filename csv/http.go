@@ -0,0 +1,171 @@
+package csv
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultUserAgent is the User-Agent which is sent if HTTPConfig.UserAgent is empty.
+const DefaultUserAgent = "tbf (+https://github.com/mpppk/tbf)"
+
+// HTTPConfig is the configuration of HTTPClient.
+type HTTPConfig struct {
+	// Timeout is the time limit of each request including reading the response body. Zero means no timeout.
+	Timeout time.Duration
+	// Retries is the number of retries after the first request fails by network error or 5xx/429 response.
+	Retries int
+	// RetryWait is the wait before the first retry. It is doubled on each retry.
+	RetryWait time.Duration
+	// Proxy is the URL of the proxy. Proxy environment variables (HTTP_PROXY, HTTPS_PROXY, NO_PROXY) are used if it is empty.
+	Proxy string
+	// CABundle is the path of PEM encoded CA certificates which are trusted in addition to the system ones.
+	CABundle  string
+	UserAgent string
+}
+
+// DefaultHTTPConfig returns the configuration of the default HTTPClient.
+func DefaultHTTPConfig() *HTTPConfig {
+	return &HTTPConfig{
+		Timeout:   30 * time.Second,
+		Retries:   3,
+		RetryWait: time.Second,
+		UserAgent: DefaultUserAgent,
+	}
+}
+
+// HTTPClient is an HTTP client which sets User-Agent and retries failed requests with exponential backoff.
+type HTTPClient struct {
+	client    *http.Client
+	retries   int
+	retryWait time.Duration
+	userAgent string
+}
+
+// NewHTTPClient returns a new HTTPClient configured by config.
+func NewHTTPClient(config *HTTPConfig) (*HTTPClient, error) {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	if config.Proxy != "" {
+		proxyURL, err := url.Parse(config.Proxy)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid proxy URL: "+config.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if config.CABundle != "" {
+		rootCAs, err := loadCABundle(config.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+	}
+
+	userAgent := config.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+
+	return &HTTPClient{
+		client:    &http.Client{Transport: transport, Timeout: config.Timeout},
+		retries:   config.Retries,
+		retryWait: config.RetryWait,
+		userAgent: userAgent,
+	}, nil
+}
+
+func loadCABundle(filePath string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read CA bundle: "+filePath)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no certificates found in CA bundle: " + filePath)
+	}
+	return pool, nil
+}
+
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// Do sends req and retries it if it fails by network error or 5xx/429 response.
+// req must not have a body because it is sent again on retry.
+func (c *HTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	wait := c.retryWait
+	for i := 0; ; i++ {
+		res, err := c.client.Do(req)
+		if err == nil && !isRetryableStatus(res.StatusCode) {
+			return res, nil
+		}
+		if i >= c.retries {
+			return res, err
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "request to %s failed, retry after %s: %v\n", req.URL, wait, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "request to %s failed, retry after %s: %s\n", req.URL, wait, res.Status)
+			res.Body.Close()
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// Get sends GET request to u.
+func (c *HTTPClient) Get(u string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request to "+u)
+	}
+	return c.Do(req)
+}
+
+var httpClient = mustNewHTTPClient(DefaultHTTPConfig())
+
+func mustNewHTTPClient(config *HTTPConfig) *HTTPClient {
+	client, err := NewHTTPClient(config)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+// SetHTTPClient replaces the HTTP client which is used by every network access in this package.
+func SetHTTPClient(client *HTTPClient) {
+	httpClient = client
+}
+
+// GetHTTPClient returns the HTTP client which is used by every network access in this package.
+func GetHTTPClient() *HTTPClient {
+	return httpClient
+}
@@ -0,0 +1,126 @@
+package csv_test
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mpppk/tbf/csv"
+)
+
+func TestHTTPClient_Retry(t *testing.T) {
+	cases := []struct {
+		failures           int
+		retries            int
+		expectedStatusCode int
+		expectedRequestNum int
+	}{
+		{failures: 0, retries: 2, expectedStatusCode: http.StatusOK, expectedRequestNum: 1},
+		{failures: 2, retries: 2, expectedStatusCode: http.StatusOK, expectedRequestNum: 3},
+		{failures: 3, retries: 2, expectedStatusCode: http.StatusServiceUnavailable, expectedRequestNum: 3},
+	}
+
+	for _, c := range cases {
+		requestNum := 0
+		var userAgent string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestNum++
+			userAgent = r.UserAgent()
+			if requestNum <= c.failures {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, "ok")
+		}))
+
+		client, err := csv.NewHTTPClient(&csv.HTTPConfig{Retries: c.retries, RetryWait: time.Millisecond, UserAgent: "tbf-test"})
+		if err != nil {
+			t.Fatalf("Unexpected error occurred: %s", err)
+		}
+
+		res, err := client.Get(server.URL)
+		if err != nil {
+			t.Errorf("Unexpected error occurred: %s", err)
+		} else {
+			res.Body.Close()
+			if res.StatusCode != c.expectedStatusCode {
+				t.Errorf("status code is expected to be %d when request fails %d times, but actually %d",
+					c.expectedStatusCode, c.failures, res.StatusCode)
+			}
+		}
+		if requestNum != c.expectedRequestNum {
+			t.Errorf("request is expected to be sent %d times when it fails %d times, but actually %d times",
+				c.expectedRequestNum, c.failures, requestNum)
+		}
+		if userAgent != "tbf-test" {
+			t.Errorf("User-Agent is expected to be tbf-test, but actually %q", userAgent)
+		}
+		server.Close()
+	}
+}
+
+func TestHTTPClient_Proxy(t *testing.T) {
+	var requestedURL string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedURL = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	client, err := csv.NewHTTPClient(&csv.HTTPConfig{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	res, err := client.Get("http://example.com/circles.csv")
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	res.Body.Close()
+
+	if requestedURL != "http://example.com/circles.csv" {
+		t.Errorf("request is expected to be sent via proxy, but proxy received %q", requestedURL)
+	}
+}
+
+func TestHTTPClient_CABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "tbf-http-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	caBundle := filepath.Join(dir, "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caBundle, certPEM, 0644); err != nil {
+		t.Fatalf("failed to write CA bundle: %s", err)
+	}
+
+	client, err := csv.NewHTTPClient(&csv.HTTPConfig{})
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Errorf("request to server with unknown certificate is expected to be error")
+	}
+
+	client, err = csv.NewHTTPClient(&csv.HTTPConfig{CABundle: caBundle})
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	res, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request is expected to succeed with CA bundle, but actually %s", err)
+	}
+	res.Body.Close()
+
+	if _, err := csv.NewHTTPClient(&csv.HTTPConfig{CABundle: filepath.Join(dir, "not-found.pem")}); err == nil {
+		t.Errorf("NewHTTPClient is expected to be error if CA bundle does not exist")
+	}
+}
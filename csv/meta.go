@@ -0,0 +1,174 @@
+package csv
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mpppk/tbf/fsutil"
+	"github.com/pkg/errors"
+)
+
+// CurrentMetaVersion is the version of the meta format which is written by WriteMeta.
+// Meta files without version are treated as version 1, which has only Checksum.
+const CurrentMetaVersion = 2
+
+// Meta is the integrity metadata of a published circle csv.
+type Meta struct {
+	Version int `json:"version,omitempty"`
+	// Checksum is CRC32 (IEEE) of the csv. It is kept in version 2 for older versions of tbf.
+	Checksum uint32 `json:"checksum"`
+	// SHA256 is the hex encoded SHA-256 digest of the csv.
+	SHA256 string `json:"sha256,omitempty"`
+	// Size is the byte size of the csv.
+	Size int64 `json:"size,omitempty"`
+	// Rows is the number of circles in the csv, excluding the header.
+	Rows          int        `json:"rows,omitempty"`
+	EventID       string     `json:"event_id,omitempty"`
+	CrawledAt     *time.Time `json:"crawled_at,omitempty"`
+	SchemaVersion int        `json:"schema_version,omitempty"`
+}
+
+// ParseMeta parses meta data in any supported version.
+func ParseMeta(contents []byte) (*Meta, error) {
+	meta := &Meta{}
+	if err := json.Unmarshal(contents, meta); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal csv meta")
+	}
+	if meta.Version == 0 {
+		meta.Version = 1
+	}
+	if meta.Version > CurrentMetaVersion {
+		return nil, fmt.Errorf("csv meta version %d is not supported (supported version is up to %d). please update tbf",
+			meta.Version, CurrentMetaVersion)
+	}
+	return meta, nil
+}
+
+// NewMeta returns the meta data of csv contents.
+// EventID and CrawledAt are left empty because they can not be derived from the contents.
+func NewMeta(contents []byte) (*Meta, error) {
+	reader, err := newReader(bytes.NewReader(contents))
+	if err != nil {
+		return nil, err
+	}
+
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read csv header")
+	}
+	schema, err := DetectSchema(headers)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := 0
+	for {
+		_, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read csv")
+		}
+		rows++
+	}
+
+	digest := sha256.Sum256(contents)
+	return &Meta{
+		Version:       CurrentMetaVersion,
+		Checksum:      crc32.ChecksumIEEE(contents),
+		SHA256:        hex.EncodeToString(digest[:]),
+		Size:          int64(len(contents)),
+		Rows:          rows,
+		SchemaVersion: schema.Version,
+	}, nil
+}
+
+// Verify returns error if contents do not match the meta data.
+// SHA-256 digest and size are used if they exist, otherwise CRC32 checksum is used.
+func (m *Meta) Verify(contents []byte) error {
+	if m.Size != 0 && m.Size != int64(len(contents)) {
+		return fmt.Errorf("size in meta is %d, but csv has %d bytes", m.Size, len(contents))
+	}
+	if m.SHA256 != "" {
+		digest := sha256.Sum256(contents)
+		if actual := hex.EncodeToString(digest[:]); !strings.EqualFold(actual, m.SHA256) {
+			return fmt.Errorf("sha256 in meta is %s, but csv has %s", m.SHA256, actual)
+		}
+		return nil
+	}
+	if checksum := crc32.ChecksumIEEE(contents); checksum != m.Checksum {
+		return fmt.Errorf("checksum in meta is %v, but csv has %v", m.Checksum, checksum)
+	}
+	return nil
+}
+
+// MetaFilePath returns the path of the meta file for the csv on csvFilePath.
+func MetaFilePath(csvFilePath string) string {
+	return strings.TrimSuffix(csvFilePath, filepath.Ext(csvFilePath)) + ".json"
+}
+
+// ReadMetaFile reads the meta file on metaFilePath.
+func ReadMetaFile(metaFilePath string) (*Meta, error) {
+	contents, err := ioutil.ReadFile(metaFilePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read csv meta from "+metaFilePath)
+	}
+	meta, err := ParseMeta(contents)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse csv meta from "+metaFilePath)
+	}
+	return meta, nil
+}
+
+// WriteMetaFile writes meta to metaFilePath.
+func WriteMetaFile(metaFilePath string, meta *Meta) error {
+	contents, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal csv meta")
+	}
+	contents = append(contents, '\n')
+	return errors.Wrap(fsutil.WriteFileAtomically(metaFilePath, contents), "failed to write csv meta to "+metaFilePath)
+}
+
+// WriteMeta writes the meta data of the csv on csvFilePath to metaFilePath in the current version.
+// EventID and CrawledAt are set to eventID and crawledAt if they are given,
+// otherwise they are taken over from the existing meta file.
+func WriteMeta(csvFilePath, metaFilePath, eventID string, crawledAt *time.Time) (*Meta, error) {
+	contents, err := ioutil.ReadFile(csvFilePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read csv file: "+csvFilePath)
+	}
+
+	meta, err := NewMeta(contents)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate meta of "+csvFilePath)
+	}
+
+	if IsExist(metaFilePath) {
+		if oldMeta, err := ReadMetaFile(metaFilePath); err == nil {
+			meta.EventID = oldMeta.EventID
+			meta.CrawledAt = oldMeta.CrawledAt
+		}
+	}
+	if eventID != "" {
+		meta.EventID = eventID
+	}
+	if crawledAt != nil {
+		meta.CrawledAt = crawledAt
+	}
+
+	if err := WriteMetaFile(metaFilePath, meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
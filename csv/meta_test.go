@@ -0,0 +1,158 @@
+package csv_test
+
+import (
+	"hash/crc32"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/mpppk/tbf/csv"
+)
+
+const metaTestCSV = v1Header + `
+https://techbookfest.org/event/tbf05/circle/1,あ01,name1,penname1,ソフトウェア全般,,,
+https://techbookfest.org/event/tbf05/circle/2,あ02,name2,penname2,ソフトウェア全般,,,
+`
+
+func TestParseMeta(t *testing.T) {
+	cases := []struct {
+		contents        string
+		expectedVersion int
+		willBeError     bool
+	}{
+		{contents: `{"checksum":3229912632}`, expectedVersion: 1},
+		{contents: `{"version":2,"checksum":1,"sha256":"abc","size":3,"rows":1,"schema_version":1}`, expectedVersion: 2},
+		{contents: `{"version":999,"checksum":1}`, willBeError: true},
+		{contents: `checksum`, willBeError: true},
+	}
+
+	for _, c := range cases {
+		meta, err := csv.ParseMeta([]byte(c.contents))
+		if c.willBeError {
+			if err == nil {
+				t.Errorf("ParseMeta is expected to be error if %q is given", c.contents)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error occurred when %q is given: %s", c.contents, err)
+			continue
+		}
+		if meta.Version != c.expectedVersion {
+			t.Errorf("meta version is expected to be %d when %q is given, but actually %d",
+				c.expectedVersion, c.contents, meta.Version)
+		}
+	}
+}
+
+func TestNewMeta(t *testing.T) {
+	meta, err := csv.NewMeta([]byte(metaTestCSV))
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	if meta.Version != csv.CurrentMetaVersion {
+		t.Errorf("meta version is expected to be %d, but actually %d", csv.CurrentMetaVersion, meta.Version)
+	}
+	if meta.Rows != 2 {
+		t.Errorf("rows is expected to be 2, but actually %d", meta.Rows)
+	}
+	if meta.Size != int64(len(metaTestCSV)) {
+		t.Errorf("size is expected to be %d, but actually %d", len(metaTestCSV), meta.Size)
+	}
+	if meta.SchemaVersion != 1 {
+		t.Errorf("schema version is expected to be 1, but actually %d", meta.SchemaVersion)
+	}
+	if meta.Checksum != crc32.ChecksumIEEE([]byte(metaTestCSV)) {
+		t.Errorf("checksum is expected to be CRC32 of csv, but actually %d", meta.Checksum)
+	}
+
+	if _, err := csv.NewMeta([]byte("unknown,header\n")); err == nil {
+		t.Errorf("NewMeta is expected to be error if csv has unknown header")
+	}
+}
+
+func TestMeta_Verify(t *testing.T) {
+	meta, err := csv.NewMeta([]byte(metaTestCSV))
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	legacyMeta := &csv.Meta{Version: 1, Checksum: meta.Checksum}
+
+	cases := []struct {
+		meta        *csv.Meta
+		contents    string
+		willBeError bool
+	}{
+		{meta: meta, contents: metaTestCSV},
+		{meta: meta, contents: metaTestCSV[:len(metaTestCSV)-1], willBeError: true},
+		{meta: meta, contents: metaTestCSV[:len(metaTestCSV)-1] + " ", willBeError: true},
+		{meta: legacyMeta, contents: metaTestCSV},
+		{meta: legacyMeta, contents: metaTestCSV + "\n", willBeError: true},
+	}
+
+	for _, c := range cases {
+		err := c.meta.Verify([]byte(c.contents))
+		if c.willBeError && err == nil {
+			t.Errorf("Verify is expected to be error if %q is given", c.contents)
+		}
+		if !c.willBeError && err != nil {
+			t.Errorf("Unexpected error occurred when %q is given: %s", c.contents, err)
+		}
+	}
+}
+
+func TestWriteMeta(t *testing.T) {
+	csvFilePath, cleanup := writeTempCSV(t, metaTestCSV)
+	defer cleanup()
+	metaFilePath := csv.MetaFilePath(csvFilePath)
+
+	crawledAt := time.Date(2018, 10, 1, 0, 0, 0, 0, time.UTC)
+	if err := csv.WriteMetaFile(metaFilePath, &csv.Meta{Checksum: 1, EventID: "tbf05", CrawledAt: &crawledAt}); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	if _, err := csv.WriteMeta(csvFilePath, metaFilePath, "", nil); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	meta, err := csv.ReadMetaFile(metaFilePath)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if meta.EventID != "tbf05" || meta.CrawledAt == nil || !meta.CrawledAt.Equal(crawledAt) {
+		t.Errorf("event id and crawled at are expected to be taken over, but actually %#v", meta)
+	}
+
+	contents, err := ioutil.ReadFile(csvFilePath)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if err := meta.Verify(contents); err != nil {
+		t.Errorf("written meta is expected to verify the csv, but actually %s", err)
+	}
+}
+
+func TestWriteMeta_override(t *testing.T) {
+	csvFilePath, cleanup := writeTempCSV(t, metaTestCSV)
+	defer cleanup()
+	metaFilePath := csv.MetaFilePath(csvFilePath)
+
+	oldCrawledAt := time.Date(2018, 10, 1, 0, 0, 0, 0, time.UTC)
+	if err := csv.WriteMetaFile(metaFilePath, &csv.Meta{Checksum: 1, EventID: "tbf05", CrawledAt: &oldCrawledAt}); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	crawledAt := time.Date(2018, 10, 8, 0, 0, 0, 0, time.UTC)
+	if _, err := csv.WriteMeta(csvFilePath, metaFilePath, "tbf04", &crawledAt); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	meta, err := csv.ReadMetaFile(metaFilePath)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if meta.EventID != "tbf04" || meta.CrawledAt == nil || !meta.CrawledAt.Equal(crawledAt) {
+		t.Errorf("event id and crawled at are expected to be overridden, but actually %#v", meta)
+	}
+}
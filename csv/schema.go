@@ -0,0 +1,107 @@
+package csv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Schema describes the columns of circle csv.
+// Columns may appear in any order in a csv file, but all of them must exist.
+type Schema struct {
+	Version int
+	Columns []string
+}
+
+// CurrentSchemaVersion is the schema version which is used to write new circle csv.
+const CurrentSchemaVersion = 2
+
+var schemas = []*Schema{
+	{
+		Version: 1,
+		Columns: []string{
+			"DetailURL",
+			"Space",
+			"Name",
+			"Penname",
+			"Genre",
+			"ImageURL",
+			"WebURL",
+			"GenreFreeFormat",
+		},
+	},
+	{
+		// version 2 records the circle image which is stored locally
+		Version: 2,
+		Columns: []string{
+			"DetailURL",
+			"Space",
+			"Name",
+			"Penname",
+			"Genre",
+			"ImageURL",
+			"WebURL",
+			"GenreFreeFormat",
+			"ImageHash",
+			"ImagePath",
+		},
+	},
+}
+
+// CurrentSchema returns the schema which is used to write new circle csv.
+func CurrentSchema() *Schema {
+	schema, _ := GetSchema(CurrentSchemaVersion)
+	return schema
+}
+
+// GetSchema returns the schema of the version.
+func GetSchema(version int) (*Schema, bool) {
+	for _, schema := range schemas {
+		if schema.Version == version {
+			return schema, true
+		}
+	}
+	return nil, false
+}
+
+// DetectSchema returns the newest schema which headers satisfy.
+func DetectSchema(headers []string) (*Schema, error) {
+	var errs []string
+	for i := len(schemas) - 1; i >= 0; i-- {
+		err := schemas[i].Validate(headers)
+		if err == nil {
+			return schemas[i], nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, fmt.Errorf("headers %q do not match any schema: %s", headers, strings.Join(errs, ", "))
+}
+
+// Validate returns error if headers do not consist of the columns of the schema.
+func (s *Schema) Validate(headers []string) error {
+	columns := map[string]bool{}
+	for _, column := range s.Columns {
+		columns[column] = true
+	}
+
+	found := map[string]bool{}
+	for _, header := range headers {
+		if !columns[header] {
+			return fmt.Errorf("schema v%d: unknown column %q", s.Version, header)
+		}
+		if found[header] {
+			return fmt.Errorf("schema v%d: duplicated column %q", s.Version, header)
+		}
+		found[header] = true
+	}
+
+	var missing []string
+	for _, column := range s.Columns {
+		if !found[column] {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("schema v%d: missing columns %q", s.Version, missing)
+	}
+	return nil
+}
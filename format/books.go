@@ -0,0 +1,185 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/mpppk/tbf/tbf"
+	"github.com/pkg/errors"
+)
+
+// BookFormatter writes books to w.
+type BookFormatter interface {
+	FormatBooks(w io.Writer, books []*tbf.Book) error
+}
+
+// BookFormatterFunc is an adapter to allow the use of ordinary functions as BookFormatter.
+type BookFormatterFunc func(w io.Writer, books []*tbf.Book) error
+
+func (f BookFormatterFunc) FormatBooks(w io.Writer, books []*tbf.Book) error {
+	return f(w, books)
+}
+
+var bookFormatters = map[string]BookFormatter{}
+
+// RegisterBook makes a book formatter available by the provided name.
+// If RegisterBook is called twice with the same name, the formatter is replaced.
+func RegisterBook(name string, formatter BookFormatter) {
+	bookFormatters[name] = formatter
+}
+
+// BookNames returns sorted names of registered book formatters.
+func BookNames() (names []string) {
+	for name := range bookFormatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// NewBookFormatter returns the book formatter which is registered as output.
+// If output starts with TemplatePrefix, the rest is used as text/template
+// which is executed for each book.
+func NewBookFormatter(output string) (BookFormatter, error) {
+	if strings.HasPrefix(output, TemplatePrefix) {
+		return NewBookTemplateFormatter(strings.TrimPrefix(output, TemplatePrefix))
+	}
+
+	formatter, ok := bookFormatters[output]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format: %s (available: %s, %s...)",
+			output, strings.Join(BookNames(), ", "), TemplatePrefix)
+	}
+	return formatter, nil
+}
+
+// BookUsage returns the description of available outputs of books for command line flags.
+func BookUsage() string {
+	return fmt.Sprintf("出力フォーマット(%s, %s<Go template>)", strings.Join(BookNames(), ", "), TemplatePrefix)
+}
+
+// PriceText returns the price of book for display, like "1000円" or "無料".
+// PriceText of the book is returned as it is if the price is unknown.
+func PriceText(book *tbf.Book) string {
+	switch {
+	case book.Price == 0:
+		return "無料"
+	case book.Price > 0:
+		return strconv.Itoa(book.Price) + "円"
+	default:
+		return book.PriceText
+	}
+}
+
+// BookSummary returns a one line summary of book like "[新刊] title (紙, 64ページ) 1000円".
+func BookSummary(book *tbf.Book) string {
+	var b strings.Builder
+	if book.IsNew {
+		b.WriteString("[新刊] ")
+	}
+	b.WriteString(book.Title)
+
+	var details []string
+	if book.Format != "" {
+		details = append(details, book.Format)
+	}
+	if book.Pages > 0 {
+		details = append(details, fmt.Sprintf("%dページ", book.Pages))
+	}
+	if len(details) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(details, ", "))
+	}
+	if price := PriceText(book); price != "" {
+		b.WriteString(" " + price)
+	}
+	return b.String()
+}
+
+func formatBooksText(w io.Writer, books []*tbf.Book) error {
+	for _, book := range books {
+		if _, err := fmt.Fprintf(w, "%s %s\n", book.Space, BookSummary(book)); err != nil {
+			return errors.Wrap(err, "failed to write book as text")
+		}
+	}
+	return nil
+}
+
+func formatBooksTable(w io.Writer, books []*tbf.Book) error {
+	headers := []string{"Space", "Title", "Price", "Pages", "Format", "New"}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	for _, book := range books {
+		pages := ""
+		if book.Pages > 0 {
+			pages = strconv.Itoa(book.Pages)
+		}
+		isNew := ""
+		if book.IsNew {
+			isNew = "新刊"
+		}
+		values := []string{book.Space, book.Title, PriceText(book), pages, book.Format, isNew}
+		for i, v := range values {
+			values[i] = strings.Replace(v, "\t", " ", -1)
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	return errors.Wrap(tw.Flush(), "failed to write books as table")
+}
+
+func formatBooksJSON(w io.Writer, books []*tbf.Book) error {
+	if books == nil {
+		books = []*tbf.Book{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return errors.Wrap(encoder.Encode(books), "failed to write books as json")
+}
+
+func formatBooksNDJSON(w io.Writer, books []*tbf.Book) error {
+	encoder := json.NewEncoder(w)
+	for _, book := range books {
+		if err := encoder.Encode(book); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to write book as json: %#v", book))
+		}
+	}
+	return nil
+}
+
+type bookTemplateFormatter struct {
+	tmpl *template.Template
+}
+
+// NewBookTemplateFormatter returns a book formatter which executes text as text/template for each book.
+// A newline is written after each execution.
+func NewBookTemplateFormatter(text string) (BookFormatter, error) {
+	tmpl, err := template.New("output").Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse output template")
+	}
+	return &bookTemplateFormatter{tmpl: tmpl}, nil
+}
+
+func (t *bookTemplateFormatter) FormatBooks(w io.Writer, books []*tbf.Book) error {
+	for _, book := range books {
+		if err := t.tmpl.Execute(w, book); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to execute output template for %s", book.Title))
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return errors.Wrap(err, "failed to write output")
+		}
+	}
+	return nil
+}
+
+func init() {
+	RegisterBook("text", BookFormatterFunc(formatBooksText))
+	RegisterBook("table", BookFormatterFunc(formatBooksTable))
+	RegisterBook("json", BookFormatterFunc(formatBooksJSON))
+	RegisterBook("ndjson", BookFormatterFunc(formatBooksNDJSON))
+}
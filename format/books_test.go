@@ -0,0 +1,103 @@
+package format_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mpppk/tbf/format"
+	"github.com/mpppk/tbf/tbf"
+)
+
+func generateBooks() []*tbf.Book {
+	return []*tbf.Book{
+		{
+			CircleDetailURL: "https://techbookfest.org/event/tbf05/circle/28360002",
+			Space:           "あ02",
+			Title:           "dummyTitle",
+			Price:           1000,
+			PriceText:       "1,000円",
+			Pages:           64,
+			Format:          "紙",
+			IsNew:           true,
+		},
+		{
+			CircleDetailURL: "https://techbookfest.org/event/tbf05/circle/28360002",
+			Space:           "あ02",
+			Title:           "oldTitle",
+			Price:           tbf.UnknownPrice,
+			PriceText:       "未定",
+		},
+	}
+}
+
+func TestNewBookFormatter(t *testing.T) {
+	cases := []struct {
+		output      string
+		willBeError bool
+	}{
+		{output: "text"},
+		{output: "table"},
+		{output: "json"},
+		{output: "ndjson"},
+		{output: "template={{.Title}}"},
+		{output: "template={{.Title", willBeError: true},
+		{output: "csv", willBeError: true},
+	}
+
+	for _, c := range cases {
+		_, err := format.NewBookFormatter(c.output)
+		if c.willBeError && err == nil {
+			t.Errorf("NewBookFormatter is expected to be error if %q is given", c.output)
+		}
+		if !c.willBeError && err != nil {
+			t.Errorf("Unexpected error occurred when %q is given: %s", c.output, err)
+		}
+	}
+}
+
+func TestBookFormatter_FormatBooks(t *testing.T) {
+	cases := []struct {
+		output   string
+		expected string
+	}{
+		{
+			output:   "text",
+			expected: "あ02 [新刊] dummyTitle (紙, 64ページ) 1000円\nあ02 oldTitle 未定\n",
+		},
+		{
+			output: "ndjson",
+			expected: `{"CircleDetailURL":"https://techbookfest.org/event/tbf05/circle/28360002","Space":"あ02","Title":"dummyTitle",` +
+				`"Price":1000,"PriceText":"1,000円","Pages":64,"Format":"紙","IsNew":true,"Description":"","ImageURLs":null}` + "\n" +
+				`{"CircleDetailURL":"https://techbookfest.org/event/tbf05/circle/28360002","Space":"あ02","Title":"oldTitle",` +
+				`"Price":-1,"PriceText":"未定","Pages":0,"Format":"","IsNew":false,"Description":"","ImageURLs":null}` + "\n",
+		},
+		{
+			output:   "template={{.Space}}:{{.Title}}",
+			expected: "あ02:dummyTitle\nあ02:oldTitle\n",
+		},
+	}
+
+	for _, c := range cases {
+		formatter, err := format.NewBookFormatter(c.output)
+		if err != nil {
+			t.Fatalf("Unexpected error occurred when %q is given: %s", c.output, err)
+		}
+		buf := &bytes.Buffer{}
+		if err := formatter.FormatBooks(buf, generateBooks()); err != nil {
+			t.Errorf("Unexpected error occurred when %q is given: %s", c.output, err)
+			continue
+		}
+		if actual := buf.String(); actual != c.expected {
+			t.Errorf("output %q is expected to be %q, but actually %q", c.output, c.expected, actual)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	formatter, _ := format.NewBookFormatter("json")
+	if err := formatter.FormatBooks(buf, nil); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if buf.String() != "[]\n" {
+		t.Errorf("empty json array is expected if no books are given, but actually %q", buf.String())
+	}
+}
@@ -0,0 +1,76 @@
+// Package format provides formatters to print circle details in various output formats.
+package format
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mpppk/tbf/tbf"
+)
+
+// Formatter writes circle details to w.
+type Formatter interface {
+	Format(w io.Writer, circleDetails []*tbf.CircleDetail) error
+}
+
+// FormatterFunc is an adapter to allow the use of ordinary functions as Formatter.
+type FormatterFunc func(w io.Writer, circleDetails []*tbf.CircleDetail) error
+
+func (f FormatterFunc) Format(w io.Writer, circleDetails []*tbf.CircleDetail) error {
+	return f(w, circleDetails)
+}
+
+// TemplatePrefix is the prefix of output names which format circle details with a text/template.
+// ex) template={{.Space}} {{.Name}}
+const TemplatePrefix = "template="
+
+var formatters = map[string]Formatter{}
+
+// Register makes a formatter available by the provided name.
+// If Register is called twice with the same name, the formatter is replaced.
+func Register(name string, formatter Formatter) {
+	formatters[name] = formatter
+}
+
+// Names returns sorted names of registered formatters.
+func Names() (names []string) {
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// New returns the formatter which is registered as output.
+// If output starts with TemplatePrefix, the rest is used as text/template
+// which is executed for each circle detail.
+func New(output string) (Formatter, error) {
+	if strings.HasPrefix(output, TemplatePrefix) {
+		return NewTemplateFormatter(strings.TrimPrefix(output, TemplatePrefix))
+	}
+
+	formatter, ok := formatters[output]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format: %s (available: %s, %s...)",
+			output, strings.Join(Names(), ", "), TemplatePrefix)
+	}
+	return formatter, nil
+}
+
+// Usage returns the description of available outputs for command line flags.
+func Usage() string {
+	return fmt.Sprintf("出力フォーマット(%s, %s<Go template>)", strings.Join(Names(), ", "), TemplatePrefix)
+}
+
+func init() {
+	Register("text", FormatterFunc(formatText))
+	Register("table", FormatterFunc(formatTable))
+	Register("json", FormatterFunc(formatJSON))
+	Register("ndjson", FormatterFunc(formatNDJSON))
+	Register("csv", FormatterFunc(formatCSV))
+	Register("tsv", FormatterFunc(formatTSV))
+	Register("yaml", FormatterFunc(formatYAML))
+	Register("markdown", FormatterFunc(formatMarkdown))
+}
@@ -0,0 +1,108 @@
+package format_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mpppk/tbf/format"
+	"github.com/mpppk/tbf/tbf"
+)
+
+func generateCircleDetails() []*tbf.CircleDetail {
+	return []*tbf.CircleDetail{
+		{
+			Circle: tbf.Circle{
+				DetailURL: "https://techbookfest.org/event/tbf05/circle/24830001",
+				Space:     "あ01",
+				Name:      "dummyName",
+				Penname:   "t_ishida,コンドウアヤ",
+				Genre:     "ソフトウェア全般",
+			},
+			ImageURL:        "dummyImageURL",
+			WebURL:          "http://example.com",
+			GenreFreeFormat: "a|b",
+		},
+	}
+}
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		output      string
+		willBeError bool
+	}{
+		{output: "text"},
+		{output: "table"},
+		{output: "json"},
+		{output: "ndjson"},
+		{output: "csv"},
+		{output: "tsv"},
+		{output: "yaml"},
+		{output: "markdown"},
+		{output: "template={{.Space}}"},
+		{output: "template={{.Space", willBeError: true},
+		{output: "unknown", willBeError: true},
+	}
+
+	for _, c := range cases {
+		_, err := format.New(c.output)
+		if c.willBeError && err == nil {
+			t.Errorf("New is expected to be error if %q is given", c.output)
+		}
+		if !c.willBeError && err != nil {
+			t.Errorf("Unexpected error occurred when %q is given: %s", c.output, err)
+		}
+	}
+}
+
+func TestFormatter_Format(t *testing.T) {
+	cases := []struct {
+		output   string
+		expected string
+	}{
+		{
+			output:   "text",
+			expected: "あ01 dummyName by t_ishida,コンドウアヤ 【ソフトウェア全般】 : a|b\n",
+		},
+		{
+			output: "ndjson",
+			expected: `{"DetailURL":"https://techbookfest.org/event/tbf05/circle/24830001","Space":"あ01","Name":"dummyName",` +
+				`"Penname":"t_ishida,コンドウアヤ","Genre":"ソフトウェア全般","ImageURL":"dummyImageURL",` +
+				`"WebURL":"http://example.com","GenreFreeFormat":"a|b","ImageHash":"","ImagePath":""}` + "\n",
+		},
+		{
+			output: "csv",
+			expected: "DetailURL,Space,Name,Penname,Genre,ImageURL,WebURL,GenreFreeFormat,ImageHash,ImagePath\n" +
+				"https://techbookfest.org/event/tbf05/circle/24830001,あ01,dummyName,\"t_ishida,コンドウアヤ\"," +
+				"ソフトウェア全般,dummyImageURL,http://example.com,a|b,,\n",
+		},
+		{
+			output: "markdown",
+			expected: "| DetailURL | Space | Name | Penname | Genre | ImageURL | WebURL | GenreFreeFormat | ImageHash | ImagePath |\n" +
+				"| --- | --- | --- | --- | --- | --- | --- | --- | --- | --- |\n" +
+				"| https://techbookfest.org/event/tbf05/circle/24830001 | あ01 | dummyName | t_ishida,コンドウアヤ |" +
+				" ソフトウェア全般 | dummyImageURL | http://example.com | a\\|b |  |  |\n",
+		},
+		{
+			output:   "template={{.Space}}: {{.WebURL}}",
+			expected: "あ01: http://example.com\n",
+		},
+	}
+
+	for _, c := range cases {
+		formatter, err := format.New(c.output)
+		if err != nil {
+			t.Errorf("Unexpected error occurred when %q is given: %s", c.output, err)
+			continue
+		}
+
+		buf := &bytes.Buffer{}
+		if err := formatter.Format(buf, generateCircleDetails()); err != nil {
+			t.Errorf("Unexpected error occurred when circle details are formatted as %q: %s", c.output, err)
+			continue
+		}
+
+		if actual := buf.String(); actual != c.expected {
+			t.Errorf("%q formatter is expected to write\n%s\nbut actually write\n%s", c.output, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package format
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/mpppk/tbf/tbf"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+func toLines(circleDetails []*tbf.CircleDetail) (headers []string, lines [][]string, err error) {
+	headers = tbf.CircleDetailColumns()
+	for _, circleDetail := range circleDetails {
+		line, err := tbf.CircleDetailToLine(headers, circleDetail)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to convert circle detail to line")
+		}
+		lines = append(lines, line)
+	}
+	return headers, lines, nil
+}
+
+func formatText(w io.Writer, circleDetails []*tbf.CircleDetail) error {
+	for _, circleDetail := range circleDetails {
+		_, err := fmt.Fprintf(w, "%s %s by %s 【%s】 : %s\n",
+			circleDetail.Space,
+			circleDetail.Name,
+			circleDetail.Penname,
+			circleDetail.Genre,
+			circleDetail.GenreFreeFormat,
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to write circle detail as text")
+		}
+	}
+	return nil
+}
+
+func formatTable(w io.Writer, circleDetails []*tbf.CircleDetail) error {
+	headers := []string{"Space", "Name", "Penname", "Genre", "GenreFreeFormat"}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	for _, circleDetail := range circleDetails {
+		m := tbf.CircleDetailToMap(circleDetail)
+		var values []string
+		for _, header := range headers {
+			values = append(values, strings.Replace(m[header], "\t", " ", -1))
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	return errors.Wrap(tw.Flush(), "failed to write circle details as table")
+}
+
+func formatJSON(w io.Writer, circleDetails []*tbf.CircleDetail) error {
+	if circleDetails == nil {
+		circleDetails = []*tbf.CircleDetail{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return errors.Wrap(encoder.Encode(circleDetails), "failed to write circle details as json")
+}
+
+func formatNDJSON(w io.Writer, circleDetails []*tbf.CircleDetail) error {
+	encoder := json.NewEncoder(w)
+	for _, circleDetail := range circleDetails {
+		if err := encoder.Encode(circleDetail); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to write circle detail as json: %#v", circleDetail))
+		}
+	}
+	return nil
+}
+
+func writeDelimited(w io.Writer, circleDetails []*tbf.CircleDetail, comma rune) error {
+	headers, lines, err := toLines(circleDetails)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	writer.Comma = comma
+	if err := writer.Write(headers); err != nil {
+		return errors.Wrap(err, "failed to write headers")
+	}
+	if err := writer.WriteAll(lines); err != nil {
+		return errors.Wrap(err, "failed to write circle details")
+	}
+	return nil
+}
+
+func formatCSV(w io.Writer, circleDetails []*tbf.CircleDetail) error {
+	return writeDelimited(w, circleDetails, ',')
+}
+
+func formatTSV(w io.Writer, circleDetails []*tbf.CircleDetail) error {
+	return writeDelimited(w, circleDetails, '\t')
+}
+
+func formatYAML(w io.Writer, circleDetails []*tbf.CircleDetail) error {
+	headers, lines, err := toLines(circleDetails)
+	if err != nil {
+		return err
+	}
+
+	items := []yaml.MapSlice{}
+	for _, line := range lines {
+		item := yaml.MapSlice{}
+		for i, header := range headers {
+			item = append(item, yaml.MapItem{Key: header, Value: line[i]})
+		}
+		items = append(items, item)
+	}
+
+	contents, err := yaml.Marshal(items)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal circle details to yaml")
+	}
+	_, err = w.Write(contents)
+	return errors.Wrap(err, "failed to write circle details as yaml")
+}
+
+var markdownReplacer = strings.NewReplacer("|", "\\|", "\r\n", "<br>", "\n", "<br>")
+
+func formatMarkdown(w io.Writer, circleDetails []*tbf.CircleDetail) error {
+	headers, lines, err := toLines(circleDetails)
+	if err != nil {
+		return err
+	}
+
+	writeRow := func(values []string) error {
+		var escaped []string
+		for _, v := range values {
+			escaped = append(escaped, markdownReplacer.Replace(v))
+		}
+		_, err := fmt.Fprintf(w, "| %s |\n", strings.Join(escaped, " | "))
+		return err
+	}
+
+	var separators []string
+	for range headers {
+		separators = append(separators, "---")
+	}
+
+	for _, row := range append([][]string{headers, separators}, lines...) {
+		if err := writeRow(row); err != nil {
+			return errors.Wrap(err, "failed to write circle details as markdown")
+		}
+	}
+	return nil
+}
+
+type templateFormatter struct {
+	tmpl *template.Template
+}
+
+// NewTemplateFormatter returns a formatter which executes text as text/template for each circle detail.
+// A newline is written after each execution.
+func NewTemplateFormatter(text string) (Formatter, error) {
+	tmpl, err := template.New("output").Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse output template")
+	}
+	return &templateFormatter{tmpl: tmpl}, nil
+}
+
+func (t *templateFormatter) Format(w io.Writer, circleDetails []*tbf.CircleDetail) error {
+	for _, circleDetail := range circleDetails {
+		if err := t.tmpl.Execute(w, circleDetail); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to execute output template for %s", circleDetail.Space))
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return errors.Wrap(err, "failed to write output")
+		}
+	}
+	return nil
+}
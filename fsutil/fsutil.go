@@ -0,0 +1,39 @@
+// Package fsutil provides file system helpers which are shared by the packages of tbf.
+package fsutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// WriteFileAtomically writes data to a temporary file in the same directory and renames it to filePath,
+// so that readers never see a partially written file.
+// The mode of the existing file is kept, and 0644 is used for a new file.
+func WriteFileAtomically(filePath string, data []byte) error {
+	tmpFile, err := ioutil.TempFile(filepath.Dir(filePath), filepath.Base(filePath)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temp file")
+	}
+	defer os.Remove(tmpFile.Name())
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(filePath); err == nil {
+		mode = info.Mode()
+	}
+	if err := tmpFile.Chmod(mode); err != nil {
+		tmpFile.Close()
+		return errors.Wrap(err, "failed to change mode of temp file: "+tmpFile.Name())
+	}
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return errors.Wrap(err, "failed to write to temp file: "+tmpFile.Name())
+	}
+	if err := tmpFile.Close(); err != nil {
+		return errors.Wrap(err, "failed to close temp file: "+tmpFile.Name())
+	}
+	return errors.Wrap(os.Rename(tmpFile.Name(), filePath), "failed to rename temp file to "+filePath)
+}
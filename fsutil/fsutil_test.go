@@ -0,0 +1,47 @@
+package fsutil_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mpppk/tbf/fsutil"
+)
+
+func TestWriteFileAtomically(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tbf-fsutil-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "file")
+
+	if err := fsutil.WriteFileAtomically(filePath, []byte("old")); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+	if err := os.Chmod(filePath, 0600); err != nil {
+		t.Fatalf("failed to change mode of %s: %s", filePath, err)
+	}
+	if err := fsutil.WriteFileAtomically(filePath, []byte("new")); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	contents, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("failed to read %s: %s", filePath, err)
+	}
+	if string(contents) != "new" {
+		t.Errorf("file is expected to be replaced with %q, but actually %q", "new", contents)
+	}
+	if info, err := os.Stat(filePath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("mode of the existing file is expected to be kept: %v, %v", info, err)
+	}
+	fileInfos, err := ioutil.ReadDir(filepath.Dir(filePath))
+	if err != nil {
+		t.Fatalf("failed to read dir: %s", err)
+	}
+	if len(fileInfos) != 1 {
+		t.Errorf("temp files are expected to be removed, but %d files are found", len(fileInfos))
+	}
+}
@@ -0,0 +1,137 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+	tokenEq
+	tokenNotEq
+	tokenMatch
+	tokenNotMatch
+	tokenPrefix
+	tokenSuffix
+	tokenContains
+)
+
+var operatorTokens = []struct {
+	literal string
+	kind    tokenKind
+}{
+	// longer operators must be placed before shorter ones
+	{"&&", tokenAnd},
+	{"||", tokenOr},
+	{"==", tokenEq},
+	{"!=", tokenNotEq},
+	{"!~", tokenNotMatch},
+	{"^=", tokenPrefix},
+	{"$=", tokenSuffix},
+	{"*=", tokenContains},
+	{"~", tokenMatch},
+	{"!", tokenNot},
+	{"(", tokenLParen},
+	{")", tokenRParen},
+}
+
+type token struct {
+	kind    tokenKind
+	literal string
+	pos     int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q at %d", t.literal, t.pos)
+}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	pos := 0
+	for pos < len(src) {
+		r, size := utf8.DecodeRuneInString(src[pos:])
+		if unicode.IsSpace(r) {
+			pos += size
+			continue
+		}
+
+		if r == '"' {
+			literal, n, err := readString(src[pos:])
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid string literal at %d", pos)
+			}
+			tokens = append(tokens, token{kind: tokenString, literal: literal, pos: pos})
+			pos += n
+			continue
+		}
+
+		if isIdentRune(r) {
+			start := pos
+			for pos < len(src) {
+				r, size := utf8.DecodeRuneInString(src[pos:])
+				if !isIdentRune(r) {
+					break
+				}
+				pos += size
+			}
+			tokens = append(tokens, token{kind: tokenIdent, literal: src[start:pos], pos: start})
+			continue
+		}
+
+		matched := false
+		for _, op := range operatorTokens {
+			if strings.HasPrefix(src[pos:], op.literal) {
+				tokens = append(tokens, token{kind: op.kind, literal: op.literal, pos: pos})
+				pos += len(op.literal)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("unexpected character %q at %d", r, pos)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: pos}), nil
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// readString reads a double quoted string from the head of src
+// and returns the unquoted value and the number of consumed bytes.
+func readString(src string) (string, int, error) {
+	escaped := false
+	for i := 1; i < len(src); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case src[i] == '\\':
+			escaped = true
+		case src[i] == '"':
+			s, err := strconv.Unquote(src[:i+1])
+			if err != nil {
+				return "", 0, err
+			}
+			return s, i + 1, nil
+		}
+	}
+	return "", 0, errors.New("string literal is not terminated")
+}
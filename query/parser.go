@@ -0,0 +1,156 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mpppk/tbf/tbf"
+	"github.com/pkg/errors"
+)
+
+type parser struct {
+	tokens []token
+	pos    int
+	fields map[string]string
+}
+
+func newParser(tokens []token) *parser {
+	fields := map[string]string{}
+	for _, header := range tbf.CircleDetailColumns() {
+		fields[strings.ToLower(header)] = header
+	}
+	return &parser{tokens: tokens, fields: fields}
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind, description string) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, fmt.Errorf("%s is expected, but got %s", description, t)
+	}
+	return t, nil
+}
+
+// parseOr parses `and ( "||" and )*`
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses `unary ( "&&" unary )*`
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseUnary parses `"!" unary | "(" or ")" | comparison`
+func (p *parser) parseUnary() (expr, error) {
+	switch p.peek().kind {
+	case tokenNot:
+		p.next()
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{expr: e}, nil
+	case tokenLParen:
+		p.next()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen, "\")\""); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison parses `field operator string`
+func (p *parser) parseComparison() (expr, error) {
+	fieldToken, err := p.expect(tokenIdent, "field name")
+	if err != nil {
+		return nil, err
+	}
+	field, ok := p.fields[strings.ToLower(fieldToken.literal)]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %s", fieldToken)
+	}
+
+	opToken := p.next()
+	if !isComparisonOperator(opToken.kind) {
+		return nil, fmt.Errorf("comparison operator is expected after %s, but got %s", fieldToken, opToken)
+	}
+
+	valueToken, err := p.expect(tokenString, "string literal")
+	if err != nil {
+		return nil, err
+	}
+	value := valueToken.literal
+
+	switch opToken.kind {
+	case tokenEq:
+		return &compareExpr{field: field, match: func(s string) bool { return s == value }}, nil
+	case tokenNotEq:
+		return &compareExpr{field: field, match: func(s string) bool { return s != value }}, nil
+	case tokenPrefix:
+		return &compareExpr{field: field, match: func(s string) bool { return strings.HasPrefix(s, value) }}, nil
+	case tokenSuffix:
+		return &compareExpr{field: field, match: func(s string) bool { return strings.HasSuffix(s, value) }}, nil
+	case tokenContains:
+		return &compareExpr{field: field, match: func(s string) bool { return strings.Contains(s, value) }}, nil
+	case tokenMatch, tokenNotMatch:
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid regular expression %s", valueToken)
+		}
+		if opToken.kind == tokenNotMatch {
+			return &compareExpr{field: field, match: func(s string) bool { return !re.MatchString(s) }}, nil
+		}
+		return &compareExpr{field: field, match: re.MatchString}, nil
+	}
+	return nil, fmt.Errorf("unsupported operator %s", opToken)
+}
+
+func isComparisonOperator(kind tokenKind) bool {
+	switch kind {
+	case tokenEq, tokenNotEq, tokenPrefix, tokenSuffix, tokenContains, tokenMatch, tokenNotMatch:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+// Package query provides a small expression language to filter circles.
+//
+// An expression compares fields of tbf.CircleDetail with string literals
+// and combines the comparisons with boolean operators. For example:
+//
+//	Genre == "ソフトウェア全般" && GenreFreeFormat ~ "Go|Rust" && Space ^= "か"
+//
+// Supported comparison operators are
+// == (equal), != (not equal), ~ (regexp match), !~ (regexp not match),
+// ^= (has prefix), $= (has suffix) and *= (contains).
+// Comparisons can be combined with &&, || and !, and grouped with parentheses.
+// Field names are case insensitive.
+package query
+
+import (
+	"github.com/mpppk/tbf/tbf"
+	"github.com/pkg/errors"
+)
+
+type expr interface {
+	eval(fields map[string]string) bool
+}
+
+type andExpr struct {
+	left, right expr
+}
+
+func (e *andExpr) eval(fields map[string]string) bool {
+	return e.left.eval(fields) && e.right.eval(fields)
+}
+
+type orExpr struct {
+	left, right expr
+}
+
+func (e *orExpr) eval(fields map[string]string) bool {
+	return e.left.eval(fields) || e.right.eval(fields)
+}
+
+type notExpr struct {
+	expr expr
+}
+
+func (e *notExpr) eval(fields map[string]string) bool {
+	return !e.expr.eval(fields)
+}
+
+type compareExpr struct {
+	field string
+	match func(string) bool
+}
+
+func (e *compareExpr) eval(fields map[string]string) bool {
+	return e.match(fields[e.field])
+}
+
+// Query is a parsed filter expression.
+type Query struct {
+	expr expr
+}
+
+// Parse parses src as a filter expression.
+// An empty src returns a Query which matches every circle.
+func Parse(src string) (*Query, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to tokenize query")
+	}
+
+	if len(tokens) == 1 {
+		return &Query{}, nil
+	}
+
+	p := newParser(tokens)
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse query")
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, errors.Errorf("failed to parse query: unexpected %s", t)
+	}
+	return &Query{expr: e}, nil
+}
+
+// Match returns true if circleDetail satisfies the query.
+func (q *Query) Match(circleDetail *tbf.CircleDetail) bool {
+	if q.expr == nil {
+		return true
+	}
+	return q.expr.eval(tbf.CircleDetailToMap(circleDetail))
+}
+
+// Filter returns circle details which satisfy the query.
+func (q *Query) Filter(circleDetails []*tbf.CircleDetail) (filtered []*tbf.CircleDetail) {
+	for _, circleDetail := range circleDetails {
+		if q.Match(circleDetail) {
+			filtered = append(filtered, circleDetail)
+		}
+	}
+	return
+}
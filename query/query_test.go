@@ -0,0 +1,97 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/mpppk/tbf/query"
+	"github.com/mpppk/tbf/tbf"
+)
+
+func generateCircleDetail() *tbf.CircleDetail {
+	return &tbf.CircleDetail{
+		Circle: tbf.Circle{
+			DetailURL: "https://techbookfest.org/event/tbf05/circle/24830001",
+			Space:     "か46",
+			Name:      "トゲトゲ団（トゲトゲダン）",
+			Penname:   "トゲトゲ",
+			Genre:     "ソフトウェア全般",
+		},
+		GenreFreeFormat: "Go and \"Rust\" book",
+	}
+}
+
+func TestQuery_Match(t *testing.T) {
+	methodName := "Query.Match"
+	cases := []struct {
+		where    string
+		expected bool
+	}{
+		{where: ``, expected: true},
+		{where: `Genre == "ソフトウェア全般"`, expected: true},
+		{where: `Genre != "ソフトウェア全般"`, expected: false},
+		{where: `genre == "ソフトウェア全般"`, expected: true},
+		{where: `GenreFreeFormat ~ "Go|Rust"`, expected: true},
+		{where: `GenreFreeFormat !~ "^Rust"`, expected: true},
+		{where: `Space ^= "か"`, expected: true},
+		{where: `Space ^= "あ"`, expected: false},
+		{where: `DetailURL $= "24830001"`, expected: true},
+		{where: `GenreFreeFormat *= "\"Rust\""`, expected: true},
+		{where: `Genre == "ソフトウェア全般" && GenreFreeFormat ~ "Go|Rust" && Space ^= "か"`, expected: true},
+		{where: `Space ^= "あ" || Space ^= "か"`, expected: true},
+		{where: `Space ^= "あ" || Space ^= "か" && Name == "dummy"`, expected: false},
+		{where: `(Space ^= "あ" || Space ^= "か") && !(Name == "dummy")`, expected: true},
+		{where: `!Space ^= "か"`, expected: false},
+	}
+
+	circleDetail := generateCircleDetail()
+	for _, c := range cases {
+		q, err := query.Parse(c.where)
+		if err != nil {
+			t.Errorf("Unexpected error occurred when %q is parsed: %s", c.where, err)
+			continue
+		}
+
+		if actual := q.Match(circleDetail); actual != c.expected {
+			t.Errorf("%s is expected to return %v when %q is given, but actually return %v",
+				methodName, c.expected, c.where, actual)
+		}
+	}
+}
+
+func TestParse_Error(t *testing.T) {
+	cases := []string{
+		`Genre`,
+		`Genre ==`,
+		`Genre == Space`,
+		`Unknown == "a"`,
+		`Genre = "a"`,
+		`Genre == "a`,
+		`Genre ~ "("`,
+		`(Genre == "a"`,
+		`Genre == "a")`,
+		`Genre == "a" &&`,
+		`Genre == "a" Space == "b"`,
+	}
+
+	for _, where := range cases {
+		if _, err := query.Parse(where); err == nil {
+			t.Errorf("Parse is expected to be error if %q is given", where)
+		}
+	}
+}
+
+func TestQuery_Filter(t *testing.T) {
+	circleDetail1 := generateCircleDetail()
+	circleDetail2 := generateCircleDetail()
+	circleDetail2.Space = "あ01"
+
+	q, err := query.Parse(`Space ^= "あ"`)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	actual := q.Filter([]*tbf.CircleDetail{circleDetail1, circleDetail2})
+	if len(actual) != 1 || actual[0] != circleDetail2 {
+		t.Errorf("Query.Filter is expected to return only circle on あ01, but actually return %v", actual)
+	}
+}
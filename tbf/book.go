@@ -0,0 +1,89 @@
+package tbf
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// UnknownPrice is the price of the book whose price can not be parsed.
+const UnknownPrice = -1
+
+// Book is a book (頒布物) which is distributed by a circle.
+// It is linked to the circle by CircleDetailURL, and Space is kept for readability of the books file.
+type Book struct {
+	CircleDetailURL string
+	Space           string
+	Title           string
+	// Price is the price in yen parsed from PriceText. It is 0 if the book is free, and UnknownPrice if it can not be parsed.
+	Price     int
+	PriceText string
+	// Pages is the page count. It is 0 if it is unknown.
+	Pages int
+	// Format is the format of the book as shown on the page, like "紙" or "電子".
+	Format string
+	// IsNew is true if the book is a new release (新刊).
+	IsNew       bool
+	Description string
+	ImageURLs   []string
+}
+
+var numberRegExp = regexp.MustCompile(`[0-9]+`)
+
+var fullWidthDigitReplacer = strings.NewReplacer(
+	"０", "0", "１", "1", "２", "2", "３", "3", "４", "4",
+	"５", "5", "６", "6", "７", "7", "８", "8", "９", "9",
+	"，", ",",
+)
+
+// firstNumber returns the first number in s. Full width digits and thousands separators are accepted.
+func firstNumber(s string) (int, bool) {
+	s = strings.Replace(fullWidthDigitReplacer.Replace(s), ",", "", -1)
+	n, err := strconv.Atoi(numberRegExp.FindString(s))
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// ParsePrice parses price text like "1,000円" or "¥500" and returns the price in yen.
+// If the text has several prices, the first one is returned.
+// It returns 0 for "無料" or "free", and UnknownPrice and false if no price is found.
+func ParsePrice(text string) (int, bool) {
+	if price, ok := firstNumber(text); ok {
+		return price, true
+	}
+	lower := strings.ToLower(text)
+	if strings.Contains(lower, "無料") || strings.Contains(lower, "free") {
+		return 0, true
+	}
+	return UnknownPrice, false
+}
+
+// ParsePages parses page count text like "64ページ" or "64p".
+// It returns 0 and false if no number is found.
+func ParsePages(text string) (int, bool) {
+	return firstNumber(text)
+}
+
+// SortBooks sorts books by the space of their circles.
+// Books of the same circle keep their order on the circle detail page.
+func SortBooks(books []*Book) {
+	sort.SliceStable(books, func(i, j int) bool {
+		a, b := books[i], books[j]
+		if c := CompareSpaces(a.Space, b.Space); c != 0 {
+			return c < 0
+		}
+		return a.CircleDetailURL < b.CircleDetailURL
+	})
+}
+
+// GroupBooksByCircle returns books grouped by CircleDetailURL.
+func GroupBooksByCircle(books []*Book) map[string][]*Book {
+	m := map[string][]*Book{}
+	for _, book := range books {
+		m[book.CircleDetailURL] = append(m[book.CircleDetailURL], book)
+	}
+	return m
+}
@@ -0,0 +1,76 @@
+package tbf_test
+
+import (
+	"testing"
+
+	"github.com/mpppk/tbf/tbf"
+)
+
+func TestParsePrice(t *testing.T) {
+	cases := []struct {
+		text          string
+		expected      int
+		expectedFound bool
+	}{
+		{text: "1000円", expected: 1000, expectedFound: true},
+		{text: "1,000円", expected: 1000, expectedFound: true},
+		{text: "¥ 500", expected: 500, expectedFound: true},
+		{text: "１，５００円", expected: 1500, expectedFound: true},
+		{text: "1000円（電子版 500円）", expected: 1000, expectedFound: true},
+		{text: "無料", expected: 0, expectedFound: true},
+		{text: "Free", expected: 0, expectedFound: true},
+		{text: "未定", expected: tbf.UnknownPrice},
+		{text: "", expected: tbf.UnknownPrice},
+	}
+
+	for _, c := range cases {
+		actual, found := tbf.ParsePrice(c.text)
+		if actual != c.expected || found != c.expectedFound {
+			t.Errorf("ParsePrice is expected to return (%d, %t) when %q is given, but actually return (%d, %t)",
+				c.expected, c.expectedFound, c.text, actual, found)
+		}
+	}
+}
+
+func TestParsePages(t *testing.T) {
+	cases := []struct {
+		text          string
+		expected      int
+		expectedFound bool
+	}{
+		{text: "64ページ", expected: 64, expectedFound: true},
+		{text: "128p", expected: 128, expectedFound: true},
+		{text: "３６頁", expected: 36, expectedFound: true},
+		{text: "", expected: 0},
+	}
+
+	for _, c := range cases {
+		actual, found := tbf.ParsePages(c.text)
+		if actual != c.expected || found != c.expectedFound {
+			t.Errorf("ParsePages is expected to return (%d, %t) when %q is given, but actually return (%d, %t)",
+				c.expected, c.expectedFound, c.text, actual, found)
+		}
+	}
+}
+
+func TestSortBooks(t *testing.T) {
+	books := []*tbf.Book{
+		{CircleDetailURL: "https://techbookfest.org/event/tbf05/circle/3", Space: "か01", Title: "book3"},
+		{CircleDetailURL: "https://techbookfest.org/event/tbf05/circle/2", Space: "あ10", Title: "book2-1"},
+		{CircleDetailURL: "https://techbookfest.org/event/tbf05/circle/1", Space: "あ02", Title: "book1"},
+		{CircleDetailURL: "https://techbookfest.org/event/tbf05/circle/2", Space: "あ10", Title: "book2-2"},
+	}
+	tbf.SortBooks(books)
+
+	expected := []string{"book1", "book2-1", "book2-2", "book3"}
+	for i, title := range expected {
+		if books[i].Title != title {
+			t.Errorf("book %d is expected to be %s, but actually %s", i, title, books[i].Title)
+		}
+	}
+
+	groups := tbf.GroupBooksByCircle(books)
+	if n := len(groups["https://techbookfest.org/event/tbf05/circle/2"]); n != 2 {
+		t.Errorf("circle 2 is expected to have 2 books, but actually %d", n)
+	}
+}
@@ -0,0 +1,191 @@
+package tbf
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Event represents a tech book festival.
+type Event struct {
+	// ID is the identifier of the event which is used in URLs of techbookfest.org like "tbf05".
+	ID string `yaml:"id"`
+	// Aliases are alternative names of the event which can be used instead of ID like "tbf5".
+	Aliases []string `yaml:"aliases,omitempty"`
+	Name    string   `yaml:"name"`
+	// Date is the date of the event in YYYY-MM-DD format.
+	Date          string `yaml:"date"`
+	Venue         string `yaml:"venue"`
+	CircleListURL string `yaml:"circle_list_url"`
+	// DataCSVURL is the URL of the crawled circle csv of the event.
+	DataCSVURL string `yaml:"data_csv_url"`
+	// Genres are IDs of the genres which circles of the event can choose. Any known genre is allowed if it is empty.
+	Genres []string `yaml:"genres,omitempty"`
+	// Selectors is the name of the built-in selector profile or the path of the selector profile file
+	// which is used to crawl the event. The default profile is used if it is empty.
+	Selectors string `yaml:"selectors,omitempty"`
+}
+
+// Validate returns error if the event lacks required fields or has malformed values.
+func (e *Event) Validate() error {
+	if e.ID == "" {
+		return errors.New("event id is empty")
+	}
+	if e.Date != "" {
+		if _, err := time.Parse("2006-01-02", e.Date); err != nil {
+			return errors.Wrapf(err, "event %s has invalid date", e.ID)
+		}
+	}
+	if e.CircleListURL != "" {
+		if err := ValidateURL(e.CircleListURL); err != nil {
+			return errors.Wrapf(err, "event %s has invalid circle list URL", e.ID)
+		}
+	}
+	if e.DataCSVURL != "" {
+		if err := ValidateURL(e.DataCSVURL); err != nil {
+			return errors.Wrapf(err, "event %s has invalid data csv URL", e.ID)
+		}
+	}
+	for _, id := range e.Genres {
+		if _, ok := LookupGenre(id); !ok {
+			return fmt.Errorf("event %s has unknown genre %q (available: %s)", e.ID, id, strings.Join(GenreIDs(), ", "))
+		}
+	}
+	return nil
+}
+
+// LookupGenre returns the genre which has name as ID or label.
+// It returns error if the genre is unknown or is not a genre of the event.
+func (e *Event) LookupGenre(name string) (*Genre, error) {
+	genre, ok := LookupGenre(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown genre %q", name)
+	}
+	if len(e.Genres) == 0 {
+		return genre, nil
+	}
+	for _, id := range e.Genres {
+		if genre.Matches(id) {
+			return genre, nil
+		}
+	}
+	return nil, fmt.Errorf("genre %q is not a genre of %s", name, e.ID)
+}
+
+// Names returns ID and aliases of the event.
+func (e *Event) Names() []string {
+	return append([]string{e.ID}, e.Aliases...)
+}
+
+// LatestEventName is the name which always refers to the latest event in the registry.
+const LatestEventName = "latest"
+
+// EventRegistry is a set of known events.
+type EventRegistry struct {
+	// Latest is the ID of the event which is referred by LatestEventName.
+	Latest string   `yaml:"latest"`
+	Events []*Event `yaml:"events"`
+}
+
+// Get returns the event which has name as ID or alias.
+// LatestEventName returns the latest event.
+func (r *EventRegistry) Get(name string) (*Event, bool) {
+	if name == LatestEventName {
+		name = r.Latest
+	}
+	for _, event := range r.Events {
+		for _, n := range event.Names() {
+			if n == name {
+				return event, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Merge adds events of other to the registry. Events which have the same ID are replaced.
+func (r *EventRegistry) Merge(other *EventRegistry) {
+	for _, event := range other.Events {
+		replaced := false
+		for i, e := range r.Events {
+			if e.ID == event.ID {
+				r.Events[i] = event
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			r.Events = append(r.Events, event)
+		}
+	}
+	if other.Latest != "" {
+		r.Latest = other.Latest
+	}
+}
+
+// Validate returns error if the registry has invalid or duplicated events.
+func (r *EventRegistry) Validate() error {
+	names := map[string]string{}
+	for _, event := range r.Events {
+		if err := event.Validate(); err != nil {
+			return err
+		}
+		for _, name := range event.Names() {
+			if name == LatestEventName {
+				return fmt.Errorf("event %s can not use reserved name %q", event.ID, name)
+			}
+			if id, ok := names[name]; ok {
+				return fmt.Errorf("event name %q is used by both %s and %s", name, id, event.ID)
+			}
+			names[name] = event.ID
+		}
+	}
+	if _, ok := r.Get(r.Latest); r.Latest != "" && !ok {
+		return fmt.Errorf("latest event %q is not found", r.Latest)
+	}
+	return nil
+}
+
+// LoadEventRegistry reads an event registry in YAML format from r.
+func LoadEventRegistry(r io.Reader) (*EventRegistry, error) {
+	contents, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read event registry")
+	}
+
+	registry := &EventRegistry{}
+	if err := yaml.UnmarshalStrict(contents, registry); err != nil {
+		return nil, errors.Wrap(err, "failed to parse event registry")
+	}
+	if err := registry.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid event registry")
+	}
+	return registry, nil
+}
+
+// LoadEventRegistryFile reads an event registry file and merges it into the default registry.
+func LoadEventRegistryFile(filePath string) (*EventRegistry, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open event registry file: "+filePath)
+	}
+	defer file.Close()
+
+	registry, err := LoadEventRegistry(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load event registry file: "+filePath)
+	}
+
+	merged := DefaultEventRegistry()
+	merged.Merge(registry)
+	if err := merged.Validate(); err != nil {
+		return nil, errors.Wrap(err, "failed to merge event registry file: "+filePath)
+	}
+	return merged, nil
+}
@@ -0,0 +1,42 @@
+package tbf
+
+import (
+	"strings"
+)
+
+// defaultEventRegistryYAML is the built-in event registry.
+// Events which are not listed here can be added by an event registry file in the same format.
+const defaultEventRegistryYAML = `
+latest: tbf05
+events:
+  - id: tbf04
+    aliases: [tbf4]
+    name: 技術書典4
+    date: "2018-04-22"
+    venue: 秋葉原UDX アキバ・スクエア
+    circle_list_url: https://techbookfest.org/event/tbf04/circle
+    data_csv_url: https://raw.githubusercontent.com/mpppk/tbf/master/data/tbf4_circles.csv
+    genres: [software, hardware, science, other]
+    selectors: tbf-2018
+  - id: tbf05
+    aliases: [tbf5]
+    name: 技術書典5
+    date: "2018-10-08"
+    venue: 池袋サンシャインシティ 展示ホールC
+    circle_list_url: https://techbookfest.org/event/tbf05/circle
+    data_csv_url: https://raw.githubusercontent.com/mpppk/tbf/master/data/tbf5_circles.csv
+    genres: [software, hardware, science, other]
+    selectors: tbf-2018
+`
+
+// DefaultEventRegistry returns a new copy of the built-in event registry.
+func DefaultEventRegistry() *EventRegistry {
+	registry, err := LoadEventRegistry(strings.NewReader(defaultEventRegistryYAML))
+	if err != nil {
+		panic(err)
+	}
+	return registry
+}
+
+// Events is the event registry which is used to resolve event names.
+var Events = DefaultEventRegistry()
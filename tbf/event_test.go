@@ -0,0 +1,100 @@
+package tbf_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mpppk/tbf/tbf"
+)
+
+func TestDefaultEventRegistry(t *testing.T) {
+	registry := tbf.DefaultEventRegistry()
+	cases := []struct {
+		name       string
+		expectedID string
+	}{
+		{name: "tbf04", expectedID: "tbf04"},
+		{name: "tbf4", expectedID: "tbf04"},
+		{name: "tbf05", expectedID: "tbf05"},
+		{name: "tbf5", expectedID: "tbf05"},
+		{name: tbf.LatestEventName, expectedID: registry.Latest},
+	}
+
+	for _, c := range cases {
+		event, ok := registry.Get(c.name)
+		if !ok {
+			t.Errorf("event %q is not found in default event registry", c.name)
+			continue
+		}
+		if event.ID != c.expectedID {
+			t.Errorf("event %q is expected to be %s, but actually %s", c.name, c.expectedID, event.ID)
+		}
+	}
+
+	if _, ok := registry.Get("unknown"); ok {
+		t.Errorf("unknown event is expected not to be found")
+	}
+}
+
+func TestLoadEventRegistry(t *testing.T) {
+	cases := []struct {
+		yaml        string
+		willBeError bool
+	}{
+		{
+			yaml: `
+latest: tbf06
+events:
+  - id: tbf06
+    aliases: [tbf6]
+    name: 技術書典6
+    date: "2019-04-14"
+    circle_list_url: https://techbookfest.org/event/tbf06/circle
+`,
+		},
+		{yaml: "events:\n  - name: no id\n", willBeError: true},
+		{yaml: "events:\n  - id: a\n    date: 2019/04/14\n", willBeError: true},
+		{yaml: "events:\n  - id: a\n    circle_list_url: not url\n", willBeError: true},
+		{yaml: "events:\n  - id: a\n  - id: b\n    aliases: [a]\n", willBeError: true},
+		{yaml: "events:\n  - id: latest\n", willBeError: true},
+		{yaml: "latest: unknown\nevents:\n  - id: a\n", willBeError: true},
+		{yaml: "events:\n  - id: a\n    genres: [software, その他]\n"},
+		{yaml: "events:\n  - id: a\n    genres: [unknown]\n", willBeError: true},
+		{yaml: "events:\n  - id: a\n    unknown_field: a\n", willBeError: true},
+	}
+
+	for _, c := range cases {
+		_, err := tbf.LoadEventRegistry(strings.NewReader(c.yaml))
+		if c.willBeError && err == nil {
+			t.Errorf("LoadEventRegistry is expected to be error if %q is given", c.yaml)
+		}
+		if !c.willBeError && err != nil {
+			t.Errorf("Unexpected error occurred when %q is given: %s", c.yaml, err)
+		}
+	}
+}
+
+func TestEventRegistry_Merge(t *testing.T) {
+	registry := tbf.DefaultEventRegistry()
+	registry.Merge(&tbf.EventRegistry{
+		Latest: "tbf06",
+		Events: []*tbf.Event{
+			{ID: "tbf05", Name: "replaced"},
+			{ID: "tbf06", Name: "技術書典6"},
+		},
+	})
+
+	if err := registry.Validate(); err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err)
+	}
+
+	latest, ok := registry.Get(tbf.LatestEventName)
+	if !ok || latest.ID != "tbf06" {
+		t.Errorf("latest event is expected to be tbf06, but actually %v", latest)
+	}
+
+	tbf5, ok := registry.Get("tbf05")
+	if !ok || tbf5.Name != "replaced" {
+		t.Errorf("tbf05 is expected to be replaced, but actually %v", tbf5)
+	}
+}
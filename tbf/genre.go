@@ -0,0 +1,84 @@
+package tbf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultGenreLanguage is the language of the genre labels which are shown on techbookfest.org and stored in circle csv.
+const DefaultGenreLanguage = "ja"
+
+// Genre is a genre of circles.
+type Genre struct {
+	// ID is the normalized identifier of the genre like "software".
+	ID string
+	// Labels are the names of the genre keyed by language.
+	// The label in DefaultGenreLanguage is the one on techbookfest.org.
+	Labels map[string]string
+}
+
+// Label returns the label of the genre in lang.
+// The label in DefaultGenreLanguage is returned if the genre has no label in lang.
+func (g *Genre) Label(lang string) string {
+	if label, ok := g.Labels[lang]; ok {
+		return label
+	}
+	return g.Labels[DefaultGenreLanguage]
+}
+
+// Matches returns true if name is the ID or one of the labels of the genre.
+// IDs and labels are compared case-insensitively after surrounding spaces are trimmed.
+func (g *Genre) Matches(name string) bool {
+	name = strings.TrimSpace(name)
+	if strings.EqualFold(name, g.ID) {
+		return true
+	}
+	for _, label := range g.Labels {
+		if strings.EqualFold(name, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// Genres are the genres known by tbf.
+var Genres = []*Genre{
+	{ID: "software", Labels: map[string]string{"ja": "ソフトウェア全般", "en": "Software"}},
+	{ID: "hardware", Labels: map[string]string{"ja": "ハードウェア全般", "en": "Hardware"}},
+	{ID: "science", Labels: map[string]string{"ja": "科学技術", "en": "Science and technology"}},
+	{ID: "other", Labels: map[string]string{"ja": "その他", "en": "Other"}},
+}
+
+// LookupGenre returns the known genre which has name as ID or label.
+func LookupGenre(name string) (*Genre, bool) {
+	for _, genre := range Genres {
+		if genre.Matches(name) {
+			return genre, true
+		}
+	}
+	return nil, false
+}
+
+// GenreIDs returns IDs of the known genres.
+func GenreIDs() (ids []string) {
+	for _, genre := range Genres {
+		ids = append(ids, genre.ID)
+	}
+	return
+}
+
+// ParseGenres parses comma separated genre IDs or labels.
+// It returns error if an unknown genre is given.
+func ParseGenres(s string) (genres []*Genre, err error) {
+	for _, name := range strings.Split(s, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		genre, ok := LookupGenre(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown genre %q (available: %s)", name, strings.Join(GenreIDs(), ", "))
+		}
+		genres = append(genres, genre)
+	}
+	return
+}
@@ -0,0 +1,114 @@
+package tbf_test
+
+import (
+	"testing"
+
+	"github.com/mpppk/tbf/tbf"
+)
+
+func TestLookupGenre(t *testing.T) {
+	cases := []struct {
+		name       string
+		expectedID string
+		willBeNG   bool
+	}{
+		{name: "software", expectedID: "software"},
+		{name: "Software", expectedID: "software"},
+		{name: "ソフトウェア全般", expectedID: "software"},
+		{name: " ハードウェア全般 ", expectedID: "hardware"},
+		{name: "Science and technology", expectedID: "science"},
+		{name: "その他", expectedID: "other"},
+		{name: "t_ishida,コンドウアヤ", willBeNG: true},
+		{name: "", willBeNG: true},
+	}
+
+	for _, c := range cases {
+		genre, ok := tbf.LookupGenre(c.name)
+		if c.willBeNG {
+			if ok {
+				t.Errorf("genre %q is expected not to be found, but actually %s is found", c.name, genre.ID)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("genre %q is expected to be found", c.name)
+			continue
+		}
+		if genre.ID != c.expectedID {
+			t.Errorf("genre %q is expected to be %s, but actually %s", c.name, c.expectedID, genre.ID)
+		}
+	}
+}
+
+func TestGenre_Label(t *testing.T) {
+	genre, _ := tbf.LookupGenre("software")
+	if label := genre.Label("en"); label != "Software" {
+		t.Errorf("english label is expected to be Software, but actually %s", label)
+	}
+	if label := genre.Label("fr"); label != "ソフトウェア全般" {
+		t.Errorf("label in unknown language is expected to be ソフトウェア全般, but actually %s", label)
+	}
+}
+
+func TestParseGenres(t *testing.T) {
+	cases := []struct {
+		s           string
+		expectedIDs []string
+		willBeError bool
+	}{
+		{s: "", expectedIDs: nil},
+		{s: "software", expectedIDs: []string{"software"}},
+		{s: "hardware,科学技術", expectedIDs: []string{"hardware", "science"}},
+		{s: "software,unknown", willBeError: true},
+	}
+
+	for _, c := range cases {
+		genres, err := tbf.ParseGenres(c.s)
+		if c.willBeError {
+			if err == nil {
+				t.Errorf("ParseGenres is expected to be error if %q is given", c.s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error occurred when %q is given: %s", c.s, err)
+			continue
+		}
+		if len(genres) != len(c.expectedIDs) {
+			t.Errorf("%d genres are expected when %q is given, but actually %d", len(c.expectedIDs), c.s, len(genres))
+			continue
+		}
+		for i, genre := range genres {
+			if genre.ID != c.expectedIDs[i] {
+				t.Errorf("genre %d is expected to be %s when %q is given, but actually %s", i, c.expectedIDs[i], c.s, genre.ID)
+			}
+		}
+	}
+}
+
+func TestEvent_LookupGenre(t *testing.T) {
+	event := &tbf.Event{ID: "tbf99", Genres: []string{"software", "other"}}
+	cases := []struct {
+		name        string
+		willBeError bool
+	}{
+		{name: "ソフトウェア全般"},
+		{name: "other"},
+		{name: "ハードウェア全般", willBeError: true},
+		{name: "unknown", willBeError: true},
+	}
+
+	for _, c := range cases {
+		_, err := event.LookupGenre(c.name)
+		if c.willBeError && err == nil {
+			t.Errorf("LookupGenre is expected to be error if %q is given", c.name)
+		}
+		if !c.willBeError && err != nil {
+			t.Errorf("Unexpected error occurred when %q is given: %s", c.name, err)
+		}
+	}
+
+	if _, err := (&tbf.Event{ID: "tbf99"}).LookupGenre("ハードウェア全般"); err != nil {
+		t.Errorf("any known genre is expected to be allowed if event has no genres, but actually %s", err)
+	}
+}
@@ -0,0 +1,74 @@
+package tbf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var circleDetailComparators = map[string]func(a, b *CircleDetail) int{
+	"space":   func(a, b *CircleDetail) int { return CompareSpaces(a.Space, b.Space) },
+	"name":    func(a, b *CircleDetail) int { return strings.Compare(a.Name, b.Name) },
+	"genre":   func(a, b *CircleDetail) int { return strings.Compare(a.Genre, b.Genre) },
+	"penname": func(a, b *CircleDetail) int { return strings.Compare(a.Penname, b.Penname) },
+}
+
+// SortKeyNames returns names which can be used as SortKey.Name.
+func SortKeyNames() []string {
+	return []string{"space", "name", "genre", "penname"}
+}
+
+// SortKey represents a key to sort circle details.
+type SortKey struct {
+	Name string
+	Desc bool
+}
+
+// ParseSortKeys parses comma separated sort keys like "genre,space:desc".
+// Each key can have ":asc" or ":desc" suffix. The default order is ascending.
+func ParseSortKeys(s string) (keys []SortKey, err error) {
+	for _, k := range strings.Split(s, ",") {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+
+		key := SortKey{Name: strings.ToLower(k)}
+		if i := strings.LastIndex(k, ":"); i >= 0 {
+			key.Name = strings.ToLower(k[:i])
+			switch strings.ToLower(k[i+1:]) {
+			case "asc":
+			case "desc":
+				key.Desc = true
+			default:
+				return nil, fmt.Errorf("invalid sort order %q in %q (asc or desc is expected)", k[i+1:], k)
+			}
+		}
+
+		if _, ok := circleDetailComparators[key.Name]; !ok {
+			return nil, fmt.Errorf("unknown sort key %q (available: %s)", key.Name, strings.Join(SortKeyNames(), ", "))
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// SortCircleDetails sorts circle details by keys.
+// Circle details which are equal on all keys are sorted by space and detail URL,
+// so the result is always deterministic.
+func SortCircleDetails(circleDetails []*CircleDetail, keys []SortKey) {
+	keys = append(append([]SortKey{}, keys...), SortKey{Name: "space"})
+	sort.SliceStable(circleDetails, func(i, j int) bool {
+		a, b := circleDetails[i], circleDetails[j]
+		for _, key := range keys {
+			c := circleDetailComparators[key.Name](a, b)
+			if key.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return a.DetailURL < b.DetailURL
+	})
+}
@@ -0,0 +1,113 @@
+package tbf_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mpppk/tbf/tbf"
+)
+
+func TestCompareSpaces(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "あ01", b: "あ01", expected: 0},
+		{a: "あ01", b: "あ02", expected: -1},
+		{a: "あ2", b: "あ10", expected: -1},
+		{a: "か01", b: "あ40", expected: 1},
+		{a: "か77", b: "こ01", expected: -1},
+		{a: "あ01a", b: "あ01b", expected: -1},
+		{a: "あ01", b: "あ01a", expected: -1},
+		{a: "1F-こ01", b: "2F-あ01", expected: -1},
+		{a: "invalid", b: "あ01", expected: 1},
+		{a: "あ01", b: "invalid", expected: -1},
+	}
+
+	for _, c := range cases {
+		if actual := tbf.CompareSpaces(c.a, c.b); actual != c.expected {
+			t.Errorf("CompareSpaces is expected to return %d when %q and %q are given, but actually return %d",
+				c.expected, c.a, c.b, actual)
+		}
+	}
+}
+
+func TestParseSortKeys(t *testing.T) {
+	cases := []struct {
+		s           string
+		expected    []tbf.SortKey
+		willBeError bool
+	}{
+		{s: "", expected: nil},
+		{s: "space", expected: []tbf.SortKey{{Name: "space"}}},
+		{s: "Genre, space:desc", expected: []tbf.SortKey{{Name: "genre"}, {Name: "space", Desc: true}}},
+		{s: "name:asc,penname:DESC", expected: []tbf.SortKey{{Name: "name"}, {Name: "penname", Desc: true}}},
+		{s: "unknown", willBeError: true},
+		{s: "space:up", willBeError: true},
+	}
+
+	for _, c := range cases {
+		actual, err := tbf.ParseSortKeys(c.s)
+		if c.willBeError {
+			if err == nil {
+				t.Errorf("ParseSortKeys is expected to be error if %q is given", c.s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error occurred when %q is given: %s", c.s, err)
+			continue
+		}
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("ParseSortKeys is expected to return %v when %q is given, but actually return %v",
+				c.expected, c.s, actual)
+		}
+	}
+}
+
+func TestSortCircleDetails(t *testing.T) {
+	newCircleDetail := func(space, genre string) *tbf.CircleDetail {
+		return &tbf.CircleDetail{Circle: tbf.Circle{Space: space, Genre: genre}}
+	}
+
+	cases := []struct {
+		keys     []tbf.SortKey
+		expected []string
+	}{
+		{
+			keys:     nil,
+			expected: []string{"あ2", "あ10", "か01", "こ40"},
+		},
+		{
+			keys:     []tbf.SortKey{{Name: "space", Desc: true}},
+			expected: []string{"こ40", "か01", "あ10", "あ2"},
+		},
+		{
+			keys:     []tbf.SortKey{{Name: "genre"}},
+			expected: []string{"あ10", "こ40", "あ2", "か01"},
+		},
+		{
+			keys:     []tbf.SortKey{{Name: "genre", Desc: true}},
+			expected: []string{"あ2", "か01", "あ10", "こ40"},
+		},
+	}
+
+	for _, c := range cases {
+		circleDetails := []*tbf.CircleDetail{
+			newCircleDetail("か01", "b"),
+			newCircleDetail("こ40", "a"),
+			newCircleDetail("あ10", "a"),
+			newCircleDetail("あ2", "b"),
+		}
+		tbf.SortCircleDetails(circleDetails, c.keys)
+
+		var actual []string
+		for _, circleDetail := range circleDetails {
+			actual = append(actual, circleDetail.Space)
+		}
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("SortCircleDetails is expected to sort spaces as %q when keys %v are given, but actually %q",
+				c.expected, c.keys, actual)
+		}
+	}
+}
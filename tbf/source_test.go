@@ -1,10 +1,14 @@
 package tbf
 
 import (
+	"path"
 	"testing"
 )
 
 func TestNewSource(t *testing.T) {
+	tbf4, _ := Events.Get("tbf04")
+	latest, _ := Events.Get(Events.Latest)
+
 	cases := []struct {
 		source   string
 		expected *Source
@@ -12,15 +16,22 @@ func TestNewSource(t *testing.T) {
 		{
 			source: "tbf4",
 			expected: &Source{
-				Url:      URLMap["tbf4"],
+				Url:      tbf4.DataCSVURL,
+				FileName: "tbf4_circles.csv",
+			},
+		},
+		{
+			source: "tbf04",
+			expected: &Source{
+				Url:      tbf4.DataCSVURL,
 				FileName: "tbf4_circles.csv",
 			},
 		},
 		{
 			source: "latest",
 			expected: &Source{
-				Url:      URLMap["latest"],
-				FileName: "latest_circles.csv",
+				Url:      latest.DataCSVURL,
+				FileName: path.Base(latest.DataCSVURL),
 			},
 		},
 		{
@@ -37,6 +48,13 @@ func TestNewSource(t *testing.T) {
 				FileName: "test_circles.csv",
 			},
 		},
+		{
+			source: "data/test_circles.csv",
+			expected: &Source{
+				Url:      "",
+				FileName: "data/test_circles.csv",
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -0,0 +1,114 @@
+package tbf
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Space represents a place of a circle in the event hall like "あ01" or "1F-か12a".
+type Space struct {
+	// Hall is the hall or floor name. It is empty if the event has only one hall.
+	Hall string
+	// Block is the kana which represents the block of the space like "あ".
+	Block string
+	// Number is the number of the space in the block.
+	Number int
+	// Half is "a" or "b" if the circle uses a half of the space, otherwise empty.
+	Half string
+}
+
+var spaceRegExp = regexp.MustCompile(`^(?:(\S+?)[-\s]+)?(\pL)(\d+)([abAB])?$`)
+
+// ParseSpace parses space string like "あ01", "あ01a" or "1F-あ01".
+func ParseSpace(space string) (Space, error) {
+	matches := spaceRegExp.FindStringSubmatch(strings.TrimSpace(space))
+	if matches == nil {
+		return Space{}, fmt.Errorf("invalid space: %q", space)
+	}
+
+	number, err := strconv.Atoi(matches[3])
+	if err != nil {
+		return Space{}, errors.Wrapf(err, "invalid space number: %q", space)
+	}
+
+	return Space{
+		Hall:   matches[1],
+		Block:  matches[2],
+		Number: number,
+		Half:   strings.ToLower(matches[4]),
+	}, nil
+}
+
+// String formats the space in canonical form. The number is padded to two digits.
+func (s Space) String() string {
+	str := fmt.Sprintf("%s%02d%s", s.Block, s.Number, s.Half)
+	if s.Hall != "" {
+		return s.Hall + "-" + str
+	}
+	return str
+}
+
+// Validate returns error if the space has invalid values.
+func (s Space) Validate() error {
+	if utf8.RuneCountInString(s.Block) != 1 {
+		return fmt.Errorf("space block must be a character: %q", s.Block)
+	}
+	if s.Number < 0 {
+		return fmt.Errorf("space number must not be negative: %d", s.Number)
+	}
+	if s.Half != "" && s.Half != "a" && s.Half != "b" {
+		return fmt.Errorf("space half must be a or b: %q", s.Half)
+	}
+	return nil
+}
+
+// Compare compares two spaces in hall order and returns -1, 0 or 1.
+// Spaces are ordered by hall, then by block (kana order, e.g. あ < か < こ),
+// then by number (あ2 < あ10), then by half (あ01 < あ01a < あ01b).
+func (s Space) Compare(other Space) int {
+	if c := strings.Compare(s.Hall, other.Hall); c != 0 {
+		return c
+	}
+	if c := strings.Compare(s.Block, other.Block); c != 0 {
+		return c
+	}
+	if s.Number != other.Number {
+		if s.Number < other.Number {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(s.Half, other.Half)
+}
+
+// NormalizeSpace returns the canonical form of space string.
+// If space is invalid, it is returned as is.
+func NormalizeSpace(space string) string {
+	s, err := ParseSpace(space)
+	if err != nil {
+		return space
+	}
+	return s.String()
+}
+
+// CompareSpaces compares two space strings in hall order and returns -1, 0 or 1.
+// Spaces which can not be parsed are placed after valid spaces and compared as strings.
+func CompareSpaces(a, b string) int {
+	aSpace, aErr := ParseSpace(a)
+	bSpace, bErr := ParseSpace(b)
+
+	switch {
+	case aErr != nil && bErr != nil:
+		return strings.Compare(a, b)
+	case aErr != nil:
+		return 1
+	case bErr != nil:
+		return -1
+	}
+	return aSpace.Compare(bSpace)
+}
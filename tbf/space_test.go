@@ -0,0 +1,71 @@
+package tbf_test
+
+import (
+	"testing"
+
+	"github.com/mpppk/tbf/tbf"
+)
+
+func TestParseSpace(t *testing.T) {
+	cases := []struct {
+		space       string
+		expected    tbf.Space
+		formatted   string
+		willBeError bool
+	}{
+		{space: "あ01", expected: tbf.Space{Block: "あ", Number: 1}, formatted: "あ01"},
+		{space: "こ40", expected: tbf.Space{Block: "こ", Number: 40}, formatted: "こ40"},
+		{space: "か1", expected: tbf.Space{Block: "か", Number: 1}, formatted: "か01"},
+		{space: "あ100", expected: tbf.Space{Block: "あ", Number: 100}, formatted: "あ100"},
+		{space: "あ01a", expected: tbf.Space{Block: "あ", Number: 1, Half: "a"}, formatted: "あ01a"},
+		{space: " け08B ", expected: tbf.Space{Block: "け", Number: 8, Half: "b"}, formatted: "け08b"},
+		{space: "1F-あ01", expected: tbf.Space{Hall: "1F", Block: "あ", Number: 1}, formatted: "1F-あ01"},
+		{space: "2F う12a", expected: tbf.Space{Hall: "2F", Block: "う", Number: 12, Half: "a"}, formatted: "2F-う12a"},
+		{space: "", willBeError: true},
+		{space: "あ", willBeError: true},
+		{space: "01", willBeError: true},
+		{space: "あ01c", willBeError: true},
+		{space: "ああ01", willBeError: true},
+	}
+
+	for _, c := range cases {
+		actual, err := tbf.ParseSpace(c.space)
+		if c.willBeError {
+			if err == nil {
+				t.Errorf("ParseSpace is expected to be error if %q is given, but actually return %#v", c.space, actual)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error occurred when %q is given: %s", c.space, err)
+			continue
+		}
+
+		if actual != c.expected {
+			t.Errorf("ParseSpace is expected to return %#v when %q is given, but actually return %#v",
+				c.expected, c.space, actual)
+		}
+		if actual.String() != c.formatted {
+			t.Errorf("Space.String is expected to return %q for %#v, but actually return %q",
+				c.formatted, actual, actual.String())
+		}
+		if err := actual.Validate(); err != nil {
+			t.Errorf("Unexpected validation error occurred for %#v: %s", actual, err)
+		}
+	}
+}
+
+func TestSpace_Validate(t *testing.T) {
+	cases := []tbf.Space{
+		{Block: "", Number: 1},
+		{Block: "ああ", Number: 1},
+		{Block: "あ", Number: -1},
+		{Block: "あ", Number: 1, Half: "c"},
+	}
+
+	for _, space := range cases {
+		if err := space.Validate(); err == nil {
+			t.Errorf("Space.Validate is expected to be error for %#v", space)
+		}
+	}
+}
@@ -12,26 +12,31 @@ import (
 	"github.com/pkg/errors"
 )
 
-var URLMap = map[string]string{
-	"latest": "https://raw.githubusercontent.com/mpppk/tbf/master/data/latest_circles.csv",
-	"tbf4":   "https://raw.githubusercontent.com/mpppk/tbf/master/data/tbf4_circles.csv",
-}
-
 var BaseURL = "https://techbookfest.org"
 
 type Circle struct {
-	DetailURL string
-	Space     string
-	Name      string
-	Penname   string
-	Genre     string
+	DetailURL string `csv:"DetailURL"`
+	Space     string `csv:"Space"`
+	Name      string `csv:"Name"`
+	Penname   string `csv:"Penname"`
+	Genre     string `csv:"Genre"`
 }
 
+// CircleDetail is a circle information which is shown on the circle detail page.
+//
+// The csv tag of each field is the column name in circle csv,
+// and the declaration order of the fields (fields of Circle first) is the canonical column order:
+// DetailURL, Space, Name, Penname, Genre, ImageURL, WebURL, GenreFreeFormat, ImageHash, ImagePath
 type CircleDetail struct {
 	Circle          `structs:",flatten" mapstructure:",squash"`
-	ImageURL        string
-	WebURL          string
-	GenreFreeFormat string
+	ImageURL        string `csv:"ImageURL"`
+	WebURL          string `csv:"WebURL"`
+	GenreFreeFormat string `csv:"GenreFreeFormat"`
+	// ImageHash is the hex encoded SHA-256 of the circle image which is stored locally. It is empty if the image is not stored.
+	ImageHash string `csv:"ImageHash"`
+	// ImagePath is the path of the stored circle image.
+	// It is relative to the image store in csv, and commands replace it with the local file path if the image exists.
+	ImagePath string `csv:"ImagePath"`
 }
 
 func NewCircleDetailFromMap(m map[string]string) (*CircleDetail, error) {
@@ -55,15 +60,25 @@ func lineToMap(headers, line []string) (m map[string]string, err error) {
 	return m, nil
 }
 
+// LineToCircleDetail converts csv line to CircleDetail.
+// If the line has malformed DetailURL or ImageURL, the circle detail is returned with MalformedURLError,
+// so that callers can read csv which is created by older tbf and treat it as a warning.
 func LineToCircleDetail(headers, line []string) (*CircleDetail, error) {
 	m, err := lineToMap(headers, line)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to convert line to map")
 	}
-	return NewCircleDetailFromMap(m)
+	circleDetail, err := NewCircleDetailFromMap(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateCircleDetailURLs(circleDetail); err != nil {
+		return circleDetail, &MalformedURLError{CircleDetail: circleDetail, Err: err}
+	}
+	return circleDetail, nil
 }
 
-func circleDetailToMap(circleDetail *CircleDetail) map[string]string {
+func CircleDetailToMap(circleDetail *CircleDetail) map[string]string {
 	m := structs.Map(circleDetail)
 	m2 := map[string]string{}
 	for k, v := range m {
@@ -73,19 +88,34 @@ func circleDetailToMap(circleDetail *CircleDetail) map[string]string {
 	return m2
 }
 
-func CircleDetailToHeaders(circleDetail *CircleDetail) (headers []string) {
-	m := circleDetailToMap(circleDetail)
-	for header := range m {
-		headers = append(headers, header)
+// CircleDetailColumns returns the csv column names of CircleDetail in canonical order.
+func CircleDetailColumns() []string {
+	return structColumns(structs.Fields(&CircleDetail{}))
+}
+
+func structColumns(fields []*structs.Field) (columns []string) {
+	for _, field := range fields {
+		if field.IsEmbedded() {
+			columns = append(columns, structColumns(field.Fields())...)
+			continue
+		}
+		if column := field.Tag("csv"); column != "" && column != "-" {
+			columns = append(columns, column)
+		}
 	}
 	return
 }
 
+// CircleDetailToHeaders returns csv headers for circleDetail in canonical order.
+func CircleDetailToHeaders(circleDetail *CircleDetail) []string {
+	return CircleDetailColumns()
+}
+
 func mapToLine(headers []string, m map[string]string) (line []string, err error) {
 	for _, header := range headers {
 		v, ok := m[header]
 		if !ok {
-			return nil, errors.Wrap(err, fmt.Sprintf("failed to convert circle detail map to line, %s not found in map", header))
+			return nil, fmt.Errorf("failed to convert circle detail map to line, %s not found in map", header)
 		}
 		line = append(line, v)
 	}
@@ -93,7 +123,7 @@ func mapToLine(headers []string, m map[string]string) (line []string, err error)
 }
 
 func CircleDetailToLine(headers []string, circleDetail *CircleDetail) ([]string, error) {
-	m := circleDetailToMap(circleDetail)
+	m := CircleDetailToMap(circleDetail)
 	line, err := mapToLine(headers, m)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to convert circle detail struct to line")
@@ -106,9 +136,11 @@ type Source struct {
 	FileName string
 }
 
+// NewSource returns a Source of sourcePath, which is a local file path, URL or event name.
+// FileName is the base name of the URL for remote sources, and sourcePath itself for local files.
 func NewSource(sourcePath string) *Source {
 	url, ok := GetCSVURL(sourcePath)
-	fileName := path.Base(sourcePath)
+	fileName := sourcePath
 	if ok {
 		fileName = path.Base(url)
 	}
@@ -123,9 +155,9 @@ func GetCSVURL(source string) (string, bool) {
 	if strings.Contains(source, "http") {
 		return source, true
 	}
-	u, ok := URLMap[source]
-	if ok {
-		return u, true
+	event, ok := Events.Get(source)
+	if ok && event.DataCSVURL != "" {
+		return event.DataCSVURL, true
 	}
 	return "", false
 }
@@ -88,18 +88,33 @@ func TestLineToCircleDetail(t *testing.T) {
 	copy(tooMuchLine, dummyLine)
 	tooMuchLine = append(tooMuchLine, "dummy elm")
 
+	validURLLine := generateDummyLine()
+	validURLLine[0] = "https://techbookfest.org/event/tbf05/circle/24830001"
+	validURLLine[5] = "https://lh3.googleusercontent.com/dummyImage"
+	validURLCircleDetail := generateDummyCircleDetail()
+	validURLCircleDetail.DetailURL = validURLLine[0]
+	validURLCircleDetail.ImageURL = validURLLine[5]
+
 	dummyCircleDetail := generateDummyCircleDetail()
 
 	cases := []struct {
-		headers     []string
-		line        []string
-		expected    *tbf.CircleDetail
-		willBeError bool
+		headers      []string
+		line         []string
+		expected     *tbf.CircleDetail
+		willBeError  bool
+		malformedURL bool
 	}{
+		{
+			headers:      circleDetailHeaders,
+			line:         dummyLine,
+			expected:     dummyCircleDetail,
+			willBeError:  false,
+			malformedURL: true,
+		},
 		{
 			headers:     circleDetailHeaders,
-			line:        dummyLine,
-			expected:    dummyCircleDetail,
+			line:        validURLLine,
+			expected:    validURLCircleDetail,
 			willBeError: false,
 		},
 		{
@@ -118,6 +133,12 @@ func TestLineToCircleDetail(t *testing.T) {
 
 	for _, c := range cases {
 		actual, err := tbf.LineToCircleDetail(c.headers, c.line)
+		if c.malformedURL {
+			if _, ok := err.(*tbf.MalformedURLError); !ok {
+				t.Errorf("%s is expected to return MalformedURLError if line %q is given, but actually %v", methodName, c.line, err)
+			}
+			err = nil
+		}
 		if err != nil && !c.willBeError {
 			t.Fatalf("Unexpected error occured in : %s", err)
 		}
@@ -186,6 +207,12 @@ func TestCircleDetailToLine(t *testing.T) {
 			expected:     generateDummyLine(),
 			willBeError:  false,
 		},
+		{
+			headers:      append(generateCircleDetailHeaders(), "UnknownHeader"),
+			circleDetail: generateDummyCircleDetail(),
+			expected:     nil,
+			willBeError:  true,
+		},
 	}
 
 	for _, c := range cases {
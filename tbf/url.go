@@ -0,0 +1,79 @@
+package tbf
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// ResolveURL resolves ref against base like a browser does for links on the base page.
+func ResolveURL(base, ref string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse base URL: "+base)
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse URL: "+ref)
+	}
+	return baseURL.ResolveReference(refURL).String(), nil
+}
+
+// ValidateURL returns error if rawURL is not an absolute http(s) URL.
+func ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return errors.Wrap(err, "malformed URL")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("malformed URL %q: scheme must be http or https", rawURL)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("malformed URL %q: host is empty", rawURL)
+	}
+	return nil
+}
+
+var collapsedSchemeRegExp = regexp.MustCompile(`^(https?):/([^/])`)
+
+// RepairURL repairs URL which was broken by older versions of the crawler.
+// "https:/techbookfest.org/..." (collapsed by path.Join) is repaired to "https://techbookfest.org/...",
+// and relative URLs like "/assets/images/dummy_cut.png" are resolved against BaseURL.
+func RepairURL(rawURL string) (string, error) {
+	if rawURL == "" {
+		return "", nil
+	}
+	repaired := collapsedSchemeRegExp.ReplaceAllString(rawURL, "$1://$2")
+	repaired, err := ResolveURL(BaseURL, repaired)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to repair URL: "+rawURL)
+	}
+	if err := ValidateURL(repaired); err != nil {
+		return "", errors.Wrap(err, "failed to repair URL: "+rawURL)
+	}
+	return repaired, nil
+}
+
+// MalformedURLError is returned by LineToCircleDetail if DetailURL or ImageURL of the circle detail is malformed.
+type MalformedURLError struct {
+	CircleDetail *CircleDetail
+	Err          error
+}
+
+func (e *MalformedURLError) Error() string {
+	return fmt.Sprintf("circle %q has malformed URL: %v (it can be repaired by `tbf csv repair-urls`)", e.CircleDetail.Name, e.Err)
+}
+
+// ValidateCircleDetailURLs returns error if DetailURL or ImageURL of circleDetail is malformed.
+// WebURL is not validated because it is a free text filled by the circle.
+func ValidateCircleDetailURLs(circleDetail *CircleDetail) error {
+	if err := ValidateURL(circleDetail.DetailURL); err != nil {
+		return errors.Wrap(err, "invalid DetailURL")
+	}
+	if circleDetail.ImageURL == "" {
+		return nil
+	}
+	return errors.Wrap(ValidateURL(circleDetail.ImageURL), "invalid ImageURL")
+}
@@ -0,0 +1,84 @@
+package tbf_test
+
+import (
+	"testing"
+
+	"github.com/mpppk/tbf/tbf"
+)
+
+func TestResolveURL(t *testing.T) {
+	cases := []struct {
+		base     string
+		ref      string
+		expected string
+	}{
+		{
+			base:     "https://techbookfest.org",
+			ref:      "/event/tbf05/circle/24830001",
+			expected: "https://techbookfest.org/event/tbf05/circle/24830001",
+		},
+		{
+			base:     "https://techbookfest.org/event/tbf05/circle",
+			ref:      "circle/24830001",
+			expected: "https://techbookfest.org/event/tbf05/circle/24830001",
+		},
+		{
+			base:     "https://techbookfest.org/event/tbf05/circle",
+			ref:      "https://example.com/a",
+			expected: "https://example.com/a",
+		},
+	}
+
+	for _, c := range cases {
+		actual, err := tbf.ResolveURL(c.base, c.ref)
+		if err != nil {
+			t.Errorf("Unexpected error occurred when %q and %q are given: %s", c.base, c.ref, err)
+			continue
+		}
+		if actual != c.expected {
+			t.Errorf("ResolveURL is expected to return %q when %q and %q are given, but actually return %q",
+				c.expected, c.base, c.ref, actual)
+		}
+	}
+}
+
+func TestRepairURL(t *testing.T) {
+	cases := []struct {
+		url         string
+		expected    string
+		willBeError bool
+	}{
+		{url: "", expected: ""},
+		{
+			url:      "https:/techbookfest.org/event/tbf05/circle/24830001",
+			expected: "https://techbookfest.org/event/tbf05/circle/24830001",
+		},
+		{
+			url:      "https://techbookfest.org/event/tbf05/circle/24830001",
+			expected: "https://techbookfest.org/event/tbf05/circle/24830001",
+		},
+		{
+			url:      "/assets/images/dummy_cut.png",
+			expected: "https://techbookfest.org/assets/images/dummy_cut.png",
+		},
+		{url: "mailto:someone@example.com", willBeError: true},
+	}
+
+	for _, c := range cases {
+		actual, err := tbf.RepairURL(c.url)
+		if c.willBeError {
+			if err == nil {
+				t.Errorf("RepairURL is expected to be error if %q is given", c.url)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error occurred when %q is given: %s", c.url, err)
+			continue
+		}
+		if actual != c.expected {
+			t.Errorf("RepairURL is expected to return %q when %q is given, but actually return %q",
+				c.expected, c.url, actual)
+		}
+	}
+}